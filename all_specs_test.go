@@ -40,6 +40,10 @@ func TestAllSpecs(t *testing.T) {
 			ProcessID:   "1",
 		})
 
+		// Queues left by previous specs would be started along with the
+		// next spec's, and hold the connection to redis
+		ResetManagers()
+
 		conn := Config.Client
 		_, err := conn.FlushDB(ctx).Result()
 		if err != nil {
@@ -59,6 +63,21 @@ func TestAllSpecs(t *testing.T) {
 	r.AddSpec(MiddlewareSpec)
 	r.AddSpec(MiddlewareRetrySpec)
 	r.AddSpec(MiddlewareStatsSpec)
+	r.AddSpec(DeadSetSpec)
+	r.AddSpec(BackoffSpec)
+	r.AddSpec(RetrySetSpec)
+	r.AddSpec(ConcurrencySpec)
+	r.AddSpec(AutoscaleSpec)
+	r.AddSpec(PauseSpec)
+	r.AddSpec(ControlSpec)
+	r.AddSpec(ServerSpec)
+	r.AddSpec(HealthSpec)
+	r.AddSpec(RateLimitSpec)
+	r.AddSpec(PartitionSpec)
+	r.AddSpec(StatusSpec)
+	r.AddSpec(ResultSpec)
+	r.AddSpec(MetricsSpec)
+	r.AddSpec(StatsSpec)
 
 	// Run GoSpec and report any errors to gotest's `testing.T` instance
 	gospec.MainGoTest(r, t)
@@ -0,0 +1,220 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_AUTOSCALE_INTERVAL = 5 * time.Second
+	DEFAULT_TARGET_LATENCY     = time.Second
+
+	// Utilization is sampled this many times per interval
+	AUTOSCALE_SAMPLES = 5
+	// Workers are added when utilization reaches SCALE_UP_UTILIZATION
+	// with a backlog, and retired below SCALE_DOWN_UTILIZATION without one.
+	SCALE_UP_UTILIZATION   = 0.8
+	SCALE_DOWN_UTILIZATION = 0.5
+)
+
+// AutoscaleOptions bounds the number of workers of an autoscaled queue
+type AutoscaleOptions struct {
+	Min int
+	Max int
+	// TargetLatency is the age of the oldest job above which workers are
+	// added. Defaults to 1 second.
+	TargetLatency time.Duration
+	// Interval between two scaling decisions. Defaults to 5 seconds.
+	Interval time.Duration
+}
+
+type autoscaler struct {
+	manager *manager
+	options AutoscaleOptions
+	closedM sync.Mutex
+	closed  chan bool
+}
+
+func newAutoscaler(m *manager, options AutoscaleOptions) *autoscaler {
+	return &autoscaler{manager: m, options: options}
+}
+
+// Autoscale grows and shrinks the workers of a queue between options.Min and
+// options.Max, based on its latency, its backlog and how busy its workers
+// are. A fleet-wide concurrency set with SetFleetConcurrency takes
+// precedence.
+func Autoscale(queue string, options AutoscaleOptions) error {
+	return defaultServer.Autoscale(queue, options)
+}
+
+// StopAutoscale stops autoscaling a queue, which goes back to its configured
+// concurrency.
+func StopAutoscale(queue string) error {
+	return defaultServer.StopAutoscale(queue)
+}
+
+// Autoscale grows and shrinks the workers of a queue of the server between
+// options.Min and options.Max.
+func (s *Server) Autoscale(queue string, options AutoscaleOptions) error {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	if options.Min < 1 || options.Max < options.Min {
+		return fmt.Errorf("invalid autoscale bounds [%d, %d] for queue %s", options.Min, options.Max, queue)
+	}
+	if options.TargetLatency == 0 {
+		options.TargetLatency = DEFAULT_TARGET_LATENCY
+	}
+	if options.Interval == 0 {
+		options.Interval = DEFAULT_AUTOSCALE_INTERVAL
+	}
+
+	m, ok := s.managers[queue]
+	if !ok {
+		return fmt.Errorf("unknown queue %s", queue)
+	}
+
+	if m.autoscaler != nil {
+		m.autoscaler.quit()
+	}
+
+	m.autoscaler = newAutoscaler(m, options)
+
+	m.workersM.Lock()
+	concurrency := m.concurrency
+	m.workersM.Unlock()
+
+	m.setAutoscaled(maxInt(options.Min, minInt(options.Max, concurrency)))
+
+	if s.started {
+		m.autoscaler.start()
+	}
+
+	return nil
+}
+
+// StopAutoscale stops autoscaling a queue of the server
+func (s *Server) StopAutoscale(queue string) error {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	m, ok := s.managers[queue]
+	if !ok {
+		return fmt.Errorf("unknown queue %s", queue)
+	}
+
+	if m.autoscaler != nil {
+		m.autoscaler.quit()
+		m.autoscaler = nil
+	}
+	m.setAutoscaled(0)
+
+	return nil
+}
+
+func (a *autoscaler) start() {
+	a.closedM.Lock()
+	defer a.closedM.Unlock()
+
+	a.closed = make(chan bool)
+
+	go (func(closed chan bool) {
+		ctx := context.Background()
+		ticker := time.NewTicker(a.options.Interval / AUTOSCALE_SAMPLES)
+		defer ticker.Stop()
+
+		busy := 0
+		samples := 0
+		for {
+			select {
+			case <-closed:
+				return
+			case <-ticker.C:
+			}
+
+			busy += a.manager.processing()
+			samples++
+
+			if samples == AUTOSCALE_SAMPLES {
+				a.scale(ctx, float64(busy)/float64(samples))
+				busy = 0
+				samples = 0
+			}
+		}
+	})(a.closed)
+}
+
+func (a *autoscaler) quit() {
+	a.closedM.Lock()
+	defer a.closedM.Unlock()
+
+	if a.closed != nil {
+		close(a.closed)
+		a.closed = nil
+	}
+}
+
+func (a *autoscaler) scale(ctx context.Context, busy float64) {
+	if a.manager.overridden() {
+		return
+	}
+
+	conn := a.manager.server.config.Client
+
+	backlog, err := conn.LLen(ctx, a.manager.queue).Result()
+	if err != nil {
+		Logger.Errorln("failed to autoscale queue", a.manager.queueName(), ":", err)
+		return
+	}
+
+	latency, err := a.manager.server.queueLatency(ctx, a.manager.queue)
+	if err != nil {
+		Logger.Errorln("failed to autoscale queue", a.manager.queueName(), ":", err)
+		return
+	}
+
+	current := a.manager.workerCount()
+	if desired := a.desired(current, busy, backlog, latency); desired != current {
+		a.manager.setAutoscaled(desired)
+	}
+}
+
+// desired returns the number of workers a queue needs given its current
+// number of workers, the average number of busy ones, its backlog and its
+// latency.
+func (a *autoscaler) desired(current int, busy float64, backlog int64, latency time.Duration) int {
+	if current == 0 {
+		return a.options.Min
+	}
+
+	utilization := busy / float64(current)
+	desired := current
+
+	switch {
+	case latency > a.options.TargetLatency, backlog > 0 && utilization >= SCALE_UP_UTILIZATION:
+		desired = current + maxInt(1, current/2)
+	case backlog == 0 && utilization < SCALE_DOWN_UTILIZATION:
+		desired = current - maxInt(1, current/4)
+		// Keep enough workers for the current load
+		desired = maxInt(desired, int(math.Ceil(busy/SCALE_UP_UTILIZATION)))
+	}
+
+	return maxInt(a.options.Min, minInt(a.options.Max, desired))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,104 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func AutoscaleSpec(c gospec.Context) {
+	ctx := context.Background()
+	const queueName = "queue-autoscale"
+
+	job := (func(message *Msg) {})
+
+	c.Specify("Autoscale", func() {
+		Process(queueName, job, 1)
+
+		c.Specify("clamps concurrency to bounds", func() {
+			err := Autoscale(queueName, AutoscaleOptions{Min: 2, Max: 10})
+
+			c.Expect(err, IsNil)
+			c.Expect(defaultServer.managers[queueName].target(), Equals, 2)
+		})
+
+		c.Specify("restores the configured concurrency once stopped", func() {
+			Autoscale(queueName, AutoscaleOptions{Min: 2, Max: 10})
+			err := StopAutoscale(queueName)
+
+			c.Expect(err, IsNil)
+			c.Expect(defaultServer.managers[queueName].target(), Equals, 1)
+			c.Expect(defaultServer.managers[queueName].autoscaler, IsNil)
+		})
+
+		c.Specify("fails for invalid bounds", func() {
+			err := Autoscale(queueName, AutoscaleOptions{Min: 5, Max: 2})
+			c.Expect(err, Not(IsNil))
+		})
+
+		c.Specify("fails for unknown queues", func() {
+			err := Autoscale("unknown", AutoscaleOptions{Min: 1, Max: 2})
+			c.Expect(err, Not(IsNil))
+		})
+
+		ResetManagers()
+	})
+
+	c.Specify("desired", func() {
+		scaler := newAutoscaler(nil, AutoscaleOptions{Min: 2, Max: 20, TargetLatency: time.Second})
+
+		c.Specify("grows when latency exceeds target", func() {
+			c.Expect(scaler.desired(4, 1, 10, 2*time.Second), Equals, 6)
+		})
+
+		c.Specify("grows when busy with a backlog", func() {
+			c.Expect(scaler.desired(4, 4, 10, 0), Equals, 6)
+		})
+
+		c.Specify("keeps workers when busy without backlog", func() {
+			c.Expect(scaler.desired(4, 3, 0, 0), Equals, 4)
+		})
+
+		c.Specify("shrinks when idle", func() {
+			c.Expect(scaler.desired(8, 1, 0, 0), Equals, 6)
+			c.Expect(scaler.desired(3, 0, 0, 0), Equals, 2)
+		})
+
+		c.Specify("stays within bounds", func() {
+			c.Expect(scaler.desired(18, 18, 100, time.Minute), Equals, 20)
+			c.Expect(scaler.desired(2, 0, 0, 0), Equals, 2)
+		})
+	})
+
+	c.Specify("grows a queue with a backlog", func() {
+		conn := Config.Client
+		done := make(chan bool)
+		slowJob := (func(message *Msg) {
+			<-done
+		})
+
+		manager := defaultServer.newManager(queueName, slowJob, 1)
+		manager.autoscaler = newAutoscaler(manager, AutoscaleOptions{Min: 1, Max: 4, TargetLatency: time.Second, Interval: 100 * time.Millisecond})
+
+		for i := 0; i < 10; i++ {
+			conn.LPush(ctx, "queue:"+queueName, fmt.Sprintf("{\"jid\":\"%d\",\"enqueued_at\":%f}", i, nowToSecondsWithNanoPrecision()-60))
+		}
+
+		manager.start()
+
+		// Scaling up takes a few intervals, longer on a loaded machine
+		deadline := time.Now().Add(5 * time.Second)
+		for manager.workerCount() < 4 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		c.Expect(manager.workerCount(), Equals, 4)
+		c.Expect(manager.concurrency, Equals, 1)
+
+		close(done)
+		manager.quit()
+	})
+}
@@ -0,0 +1,249 @@
+package workers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes how long to wait before retrying a job which failed
+// retryCount times before (0 on its first failure).
+//
+// The built-in implementations can be set on EnqueueOptions and are
+// serialized into the payload under "backoff", so jobs enqueued by other
+// services keep their policy. Custom implementations can't be serialized:
+// enqueueing them fails, they are selected per queue or per class with
+// SetQueueBackoff and SetClassBackoff instead.
+type Backoff interface {
+	Delay(retryCount int) time.Duration
+}
+
+// ConstantBackoff always waits the same delay
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+// LinearBackoff waits Base + Step * retryCount, up to Max when set
+type LinearBackoff struct {
+	Base time.Duration
+	Step time.Duration
+	Max  time.Duration
+}
+
+// ExponentialBackoff waits Base * Factor ^ retryCount, up to Max when set.
+// Factor defaults to 2.
+type ExponentialBackoff struct {
+	Base   time.Duration
+	Factor float64
+	Max    time.Duration
+}
+
+// DecorrelatedJitterBackoff waits a random delay between Base and three
+// times the previous delay, up to Max.
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// ScheduleBackoff waits Delays[retryCount], repeating the last delay once
+// the schedule is exhausted.
+type ScheduleBackoff struct {
+	Delays []time.Duration
+}
+
+// SetQueueBackoff sets the backoff of jobs failing on queue, unless their
+// payload or class specify another one.
+func SetQueueBackoff(queue string, backoff Backoff) {
+	defaultServer.SetQueueBackoff(queue, backoff)
+}
+
+// SetClassBackoff sets the backoff of jobs of class, unless their payload
+// specifies another one.
+func SetClassBackoff(class string, backoff Backoff) {
+	defaultServer.SetClassBackoff(class, backoff)
+}
+
+// Backoffs are guarded by backoffsM, as workers read them while Quit holds
+// access.
+func (s *Server) SetQueueBackoff(queue string, backoff Backoff) {
+	s.backoffsM.Lock()
+	defer s.backoffsM.Unlock()
+	s.queueBackoffs[queue] = backoff
+}
+
+func (s *Server) SetClassBackoff(class string, backoff Backoff) {
+	s.backoffsM.Lock()
+	defer s.backoffsM.Unlock()
+	s.classBackoffs[class] = backoff
+}
+
+func (b ConstantBackoff) Delay(retryCount int) time.Duration {
+	return b.Interval
+}
+
+func (b LinearBackoff) Delay(retryCount int) time.Duration {
+	return capDelay(b.Base+time.Duration(retryCount)*b.Step, b.Max)
+}
+
+func (b ExponentialBackoff) Delay(retryCount int) time.Duration {
+	factor := b.Factor
+	if factor == 0 {
+		factor = 2
+	}
+
+	delay := float64(b.Base) * math.Pow(factor, float64(retryCount))
+	if delay >= math.MaxInt64 {
+		return capDelay(math.MaxInt64, b.Max)
+	}
+
+	return capDelay(time.Duration(delay), b.Max)
+}
+
+func (b DecorrelatedJitterBackoff) Delay(retryCount int) time.Duration {
+	// The previous delay isn't stored in the payload, so replay the
+	// sequence from the first retry; each step only depends on the
+	// previous one, which yields the same distribution.
+	delay := b.Base
+	for i := 0; i <= retryCount; i++ {
+		upper := delay * 3
+		if upper < delay {
+			upper = math.MaxInt64
+		}
+		if upper <= b.Base {
+			delay = b.Base
+		} else {
+			delay = b.Base + time.Duration(rand.Int63n(int64(upper-b.Base)))
+		}
+		delay = capDelay(delay, b.Max)
+	}
+
+	return delay
+}
+
+func (b ScheduleBackoff) Delay(retryCount int) time.Duration {
+	if len(b.Delays) == 0 {
+		return 0
+	}
+	if retryCount >= len(b.Delays) {
+		return b.Delays[len(b.Delays)-1]
+	}
+	return b.Delays[retryCount]
+}
+
+func capDelay(delay, max time.Duration) time.Duration {
+	if max > 0 && delay > max {
+		return max
+	}
+	return delay
+}
+
+// backoffJson is the payload representation of the built-in backoffs, with
+// every duration in seconds.
+type backoffJson struct {
+	Type   string    `json:"type"`
+	Delay  float64   `json:"delay,omitempty"`
+	Base   float64   `json:"base,omitempty"`
+	Step   float64   `json:"step,omitempty"`
+	Factor float64   `json:"factor,omitempty"`
+	Max    float64   `json:"max,omitempty"`
+	Delays []float64 `json:"delays,omitempty"`
+}
+
+func (b ConstantBackoff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(backoffJson{Type: "constant", Delay: b.Interval.Seconds()})
+}
+
+func (b LinearBackoff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(backoffJson{Type: "linear", Base: b.Base.Seconds(), Step: b.Step.Seconds(), Max: b.Max.Seconds()})
+}
+
+func (b ExponentialBackoff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(backoffJson{Type: "exponential", Base: b.Base.Seconds(), Factor: b.Factor, Max: b.Max.Seconds()})
+}
+
+func (b DecorrelatedJitterBackoff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(backoffJson{Type: "decorrelated_jitter", Base: b.Base.Seconds(), Max: b.Max.Seconds()})
+}
+
+func (b ScheduleBackoff) MarshalJSON() ([]byte, error) {
+	delays := make([]float64, len(b.Delays))
+	for i, delay := range b.Delays {
+		delays[i] = delay.Seconds()
+	}
+	return json.Marshal(backoffJson{Type: "schedule", Delays: delays})
+}
+
+// ParseBackoff decodes a backoff serialized into a payload
+func ParseBackoff(data []byte) (Backoff, error) {
+	var b backoffJson
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+
+	switch b.Type {
+	case "constant":
+		return ConstantBackoff{seconds(b.Delay)}, nil
+	case "linear":
+		return LinearBackoff{seconds(b.Base), seconds(b.Step), seconds(b.Max)}, nil
+	case "exponential":
+		return ExponentialBackoff{seconds(b.Base), b.Factor, seconds(b.Max)}, nil
+	case "decorrelated_jitter":
+		return DecorrelatedJitterBackoff{seconds(b.Base), seconds(b.Max)}, nil
+	case "schedule":
+		delays := make([]time.Duration, len(b.Delays))
+		for i, delay := range b.Delays {
+			delays[i] = seconds(delay)
+		}
+		return ScheduleBackoff{delays}, nil
+	}
+
+	return nil, fmt.Errorf("unknown backoff type %q", b.Type)
+}
+
+// checkBackoff fails for backoffs which can't be set on EnqueueOptions
+func checkBackoff(backoff Backoff) error {
+	switch backoff.(type) {
+	case nil,
+		ConstantBackoff, *ConstantBackoff,
+		LinearBackoff, *LinearBackoff,
+		ExponentialBackoff, *ExponentialBackoff,
+		DecorrelatedJitterBackoff, *DecorrelatedJitterBackoff,
+		ScheduleBackoff, *ScheduleBackoff:
+		return nil
+	}
+
+	return fmt.Errorf("backoff %T can't be serialized, use SetQueueBackoff or SetClassBackoff", backoff)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// backoffFor returns the backoff of a failed message: the one in its
+// payload, then the one of its class, then the one of its queue. It returns
+// nil when none is set, in which case retry_options are used.
+func (s *Server) backoffFor(queue string, message *Msg) Backoff {
+	if data, ok := message.CheckGet("backoff"); ok {
+		encoded, _ := data.Encode()
+		if backoff, err := ParseBackoff(encoded); err == nil {
+			return backoff
+		} else {
+			Logger.Errorln("failed to parse backoff of", message.Jid(), ":", err)
+		}
+	}
+
+	s.backoffsM.RLock()
+	defer s.backoffsM.RUnlock()
+
+	class, _ := message.Get("class").String()
+	if backoff, ok := s.classBackoffs[class]; ok {
+		return backoff
+	}
+	if backoff, ok := s.queueBackoffs[queue]; ok {
+		return backoff
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package workers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func BackoffSpec(c gospec.Context) {
+	c.Specify("ConstantBackoff", func() {
+		backoff := ConstantBackoff{10 * time.Second}
+
+		c.Expect(backoff.Delay(0), Equals, 10*time.Second)
+		c.Expect(backoff.Delay(5), Equals, 10*time.Second)
+	})
+
+	c.Specify("LinearBackoff", func() {
+		backoff := LinearBackoff{Base: 10 * time.Second, Step: 5 * time.Second, Max: time.Minute}
+
+		c.Expect(backoff.Delay(0), Equals, 10*time.Second)
+		c.Expect(backoff.Delay(2), Equals, 20*time.Second)
+		c.Expect(backoff.Delay(100), Equals, time.Minute)
+	})
+
+	c.Specify("ExponentialBackoff", func() {
+		backoff := ExponentialBackoff{Base: time.Second, Max: time.Hour}
+
+		c.Expect(backoff.Delay(0), Equals, time.Second)
+		c.Expect(backoff.Delay(3), Equals, 8*time.Second)
+		c.Expect(backoff.Delay(100), Equals, time.Hour)
+	})
+
+	c.Specify("DecorrelatedJitterBackoff", func() {
+		backoff := DecorrelatedJitterBackoff{Base: time.Second, Max: time.Minute}
+
+		for i := 0; i < 20; i++ {
+			delay := backoff.Delay(i)
+			c.Expect(delay, Satisfies, delay >= time.Second && delay <= time.Minute)
+		}
+	})
+
+	c.Specify("ScheduleBackoff", func() {
+		backoff := ScheduleBackoff{[]time.Duration{10 * time.Second, time.Minute, 10 * time.Minute, time.Hour}}
+
+		c.Expect(backoff.Delay(0), Equals, 10*time.Second)
+		c.Expect(backoff.Delay(2), Equals, 10*time.Minute)
+		c.Expect(backoff.Delay(10), Equals, time.Hour)
+	})
+
+	c.Specify("ParseBackoff", func() {
+		c.Specify("decodes serialized backoffs", func() {
+			backoffs := []Backoff{
+				ConstantBackoff{10 * time.Second},
+				LinearBackoff{Base: 10 * time.Second, Step: 5 * time.Second},
+				ExponentialBackoff{Base: time.Second, Factor: 3, Max: time.Hour},
+				DecorrelatedJitterBackoff{Base: time.Second, Max: time.Minute},
+			}
+
+			for _, backoff := range backoffs {
+				data, _ := json.Marshal(backoff)
+				parsed, err := ParseBackoff(data)
+
+				c.Expect(err, IsNil)
+				c.Expect(parsed, Equals, backoff)
+			}
+		})
+
+		c.Specify("decodes schedules", func() {
+			data := []byte("{\"type\":\"schedule\",\"delays\":[10,60,600,3600]}")
+			parsed, err := ParseBackoff(data)
+
+			c.Expect(err, IsNil)
+			c.Expect(parsed.Delay(1), Equals, time.Minute)
+			c.Expect(parsed.Delay(3), Equals, time.Hour)
+		})
+
+		c.Specify("fails on unknown types", func() {
+			_, err := ParseBackoff([]byte("{\"type\":\"fibonacci\"}"))
+			c.Expect(err, Not(IsNil))
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetConcurrency changes the number of workers of a queue in this process.
+// Workers are added right away, while retired workers finish their current
+// job first. A fleet-wide concurrency set with SetFleetConcurrency and
+// autoscaling take precedence.
+func SetConcurrency(queue string, n int) error {
+	return defaultServer.SetConcurrency(queue, n)
+}
+
+// SetFleetConcurrency overrides the number of workers of a queue in every
+// process. Processes pick it up within Config.PoolInterval seconds.
+func SetFleetConcurrency(ctx context.Context, queue string, n int) error {
+	return defaultServer.SetFleetConcurrency(ctx, queue, n)
+}
+
+// ResetFleetConcurrency removes the fleet-wide override of a queue, so every
+// process goes back to its own concurrency.
+func ResetFleetConcurrency(ctx context.Context, queue string) error {
+	return defaultServer.ResetFleetConcurrency(ctx, queue)
+}
+
+// SetConcurrency changes the number of workers of a queue in this process.
+func (s *Server) SetConcurrency(queue string, n int) error {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	if n < 1 {
+		return fmt.Errorf("invalid concurrency %d for queue %s", n, queue)
+	}
+
+	m, ok := s.managers[queue]
+	if !ok {
+		return fmt.Errorf("unknown queue %s", queue)
+	}
+
+	m.setConcurrency(n)
+
+	return nil
+}
+
+// SetFleetConcurrency overrides the number of workers of a queue in every
+// process of the server's namespace.
+func (s *Server) SetFleetConcurrency(ctx context.Context, queue string, n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid concurrency %d for queue %s", n, queue)
+	}
+
+	return s.config.Client.HSet(ctx, s.config.Namespace+CONCURRENCY_KEY, queue, n).Err()
+}
+
+// ResetFleetConcurrency removes the fleet-wide override of a queue
+func (s *Server) ResetFleetConcurrency(ctx context.Context, queue string) error {
+	return s.config.Client.HDel(ctx, s.config.Namespace+CONCURRENCY_KEY, queue).Err()
+}
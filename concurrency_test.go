@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"context"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func ConcurrencySpec(c gospec.Context) {
+	ctx := context.Background()
+	const queueName = "queue-concurrency"
+
+	job := (func(message *Msg) {})
+
+	c.Specify("SetConcurrency", func() {
+		Process(queueName, job, 2)
+
+		c.Specify("changes the concurrency of a queue", func() {
+			err := SetConcurrency(queueName, 5)
+
+			c.Expect(err, IsNil)
+			c.Expect(defaultServer.managers[queueName].concurrency, Equals, 5)
+		})
+
+		c.Specify("fails for unknown queues", func() {
+			err := SetConcurrency("unknown", 5)
+			c.Expect(err, Not(IsNil))
+		})
+
+		c.Specify("fails for invalid concurrency", func() {
+			err := SetConcurrency(queueName, 0)
+			c.Expect(err, Not(IsNil))
+		})
+
+		ResetManagers()
+	})
+
+	c.Specify("fleet concurrency", func() {
+		Process(queueName, job, 2)
+		poller := newFleetPoller(defaultServer)
+
+		c.Specify("applies overrides stored in redis", func() {
+			SetFleetConcurrency(ctx, queueName, 7)
+			poller.poll(ctx)
+
+			c.Expect(defaultServer.managers[queueName].override, Equals, 7)
+			c.Expect(defaultServer.managers[queueName].target(), Equals, 7)
+
+			ResetFleetConcurrency(ctx, queueName)
+			poller.poll(ctx)
+
+			c.Expect(defaultServer.managers[queueName].override, Equals, 0)
+			c.Expect(defaultServer.managers[queueName].target(), Equals, 2)
+		})
+
+		ResetManagers()
+	})
+}
@@ -1,6 +1,9 @@
 package workers
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/redis/go-redis/v9"
 )
 
@@ -9,41 +12,107 @@ type Options struct {
 	Namespace    string
 	ProcessID    string
 	PoolInterval int
+
+	DeadMaxJobs          int
+	DeadTimeoutInSeconds int
+
+	ShutdownTimeout time.Duration
+
+	// StatusExpiration is how long the status of tracked jobs is kept after
+	// their last update, 24 hours by default.
+	StatusExpiration time.Duration
+
+	// ResultExpiration is how long the results of jobs are kept, an hour by
+	// default.
+	ResultExpiration time.Duration
+
+	// DailyStatsExpiration is how long the daily stats counters, global and
+	// per queue and class, are kept. Zero keeps them forever.
+	DailyStatsExpiration time.Duration
 }
 
 type WorkerConfig struct {
-	processId    string
-	Namespace    string
-	PoolInterval int
-	Client       redis.UniversalClient
-	Fetch        func(queue string) Fetcher
+	processId            string
+	Namespace            string
+	PoolInterval         int
+	DeadMaxJobs          int
+	DeadTimeoutInSeconds int
+	ShutdownTimeout      time.Duration
+	StatusExpiration     time.Duration
+	ResultExpiration     time.Duration
+	DailyStatsExpiration time.Duration
+	Client               redis.UniversalClient
+	Fetch                func(queue string) Fetcher
 }
 
 var Config *WorkerConfig
 
+// Configure sets up the configuration of the package-level functions
 func Configure(options Options) {
+	Config = newConfig(options)
+	defaultServer.config = Config
+	defaultClient.config = Config
+}
+
+func newConfig(options Options) *WorkerConfig {
+	config := newClientConfig(options)
+
+	if options.ProcessID == "" {
+		panic("Configure requires a 'ProcessID' option, which uniquely identifies this instance")
+	}
+
+	return config
+}
+
+// newClientConfig builds a configuration which only enqueues jobs, and so
+// doesn't need a ProcessID.
+func newClientConfig(options Options) *WorkerConfig {
 	var namespace string
 
 	if options.RedisClient == nil {
 		panic("Configure requires a redis client interface")
 	}
-	if options.ProcessID == "" {
-		panic("Configure requires a 'ProcessID' option, which uniquely identifies this instance")
-	}
 	if options.Namespace != "" {
 		namespace = options.Namespace + ":"
 	}
 	if options.PoolInterval == 0 {
 		options.PoolInterval = 15
 	}
+	if options.DeadMaxJobs == 0 {
+		options.DeadMaxJobs = DEFAULT_DEAD_MAX_JOBS
+	}
+	if options.DeadTimeoutInSeconds == 0 {
+		options.DeadTimeoutInSeconds = DEFAULT_DEAD_TIMEOUT
+	}
+	if options.StatusExpiration == 0 {
+		options.StatusExpiration = DEFAULT_STATUS_EXPIRATION
+	}
+	if options.ResultExpiration == 0 {
+		options.ResultExpiration = DEFAULT_RESULT_EXPIRATION
+	}
 
-	Config = &WorkerConfig{
+	config := &WorkerConfig{
 		options.ProcessID,
 		namespace,
 		options.PoolInterval,
+		options.DeadMaxJobs,
+		options.DeadTimeoutInSeconds,
+		options.ShutdownTimeout,
+		options.StatusExpiration,
+		options.ResultExpiration,
+		options.DailyStatsExpiration,
 		options.RedisClient,
-		func(queue string) Fetcher {
-			return NewFetch(queue, make(chan *Msg), make(chan bool))
-		},
+		nil,
+	}
+	config.Fetch = func(queue string) Fetcher {
+		return newFetch(config, queue, make(chan *Msg), make(chan bool))
 	}
+
+	return config
+}
+
+// inprogressQueue returns the list holding the messages of a queue being
+// processed by this process.
+func (c *WorkerConfig) inprogressQueue(queue string) string {
+	return fmt.Sprint(queue, ":", c.processId, ":inprogress")
 }
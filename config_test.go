@@ -96,4 +96,9 @@ func ConfigSpec(c gospec.Context) {
 
 		c.Expect(Config.PoolInterval, Equals, 1)
 	})
+
+	c.Specify("defaults dead set limits", func() {
+		c.Expect(Config.DeadMaxJobs, Equals, 10000)
+		c.Expect(Config.DeadTimeoutInSeconds, Equals, 180*24*60*60)
+	})
 }
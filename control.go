@@ -0,0 +1,230 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Commands understood by processes on their control channels. Pause, resume
+// and set-concurrency take arguments: "pause:<queue>", "resume:<queue>" and
+// "set-concurrency:<queue>:<n>".
+const (
+	COMMAND_QUIET           = "quiet"
+	COMMAND_STOP            = "stop"
+	COMMAND_PAUSE           = "pause"
+	COMMAND_RESUME          = "resume"
+	COMMAND_SET_CONCURRENCY = "set-concurrency"
+	COMMAND_DUMP_BUSY       = "dump-busy"
+)
+
+// controlRequest is the JSON body of a request to the Control handler
+type controlRequest struct {
+	Command string `json:"command"`
+	Process string `json:"process"`
+}
+
+type command struct {
+	name        string
+	queue       string
+	concurrency int
+}
+
+// controller carries out the commands published on the control channel of
+// this process and on the broadcast channel.
+type controller struct {
+	server *Server
+	pubsub *redis.PubSub
+}
+
+// SendCommand publishes a command to the process with the given ProcessID,
+// and returns the number of processes which received it.
+func SendCommand(ctx context.Context, processID, cmd string) (int64, error) {
+	return defaultServer.SendCommand(ctx, processID, cmd)
+}
+
+// BroadcastCommand publishes a command to every process, and returns the
+// number of processes which received it.
+func BroadcastCommand(ctx context.Context, cmd string) (int64, error) {
+	return defaultServer.BroadcastCommand(ctx, cmd)
+}
+
+// Control sends the command of a JSON POST request to the process given in
+// its "process" field, or to every process without one. It isn't served by
+// StatsServer: it changes the state of processes, so mount it on a server of
+// your own, behind authentication.
+func Control(w http.ResponseWriter, req *http.Request) {
+	defaultServer.Control(w, req)
+}
+
+// SendCommand publishes a command to the process of the server's namespace
+// with the given ProcessID.
+func (s *Server) SendCommand(ctx context.Context, processID, cmd string) (int64, error) {
+	return s.publishCommand(ctx, s.controlChannel(processID), cmd)
+}
+
+// BroadcastCommand publishes a command to every process of the server's
+// namespace.
+func (s *Server) BroadcastCommand(ctx context.Context, cmd string) (int64, error) {
+	return s.publishCommand(ctx, s.broadcastChannel(), cmd)
+}
+
+func (s *Server) publishCommand(ctx context.Context, channel, cmd string) (int64, error) {
+	if _, err := parseCommand(cmd); err != nil {
+		return 0, err
+	}
+
+	return s.config.Client.Publish(ctx, channel, cmd).Result()
+}
+
+// Control is the http handler sending commands to the processes of the
+// server's namespace.
+func (s *Server) Control(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Browsers send forms to any origin without asking it first, a JSON
+	// body keeps other sites from sending commands.
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var request controlRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 1<<16)).Decode(&request); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var receivers int64
+	var err error
+	if request.Process != "" {
+		receivers, err = s.SendCommand(req.Context(), request.Process, request.Command)
+	} else {
+		receivers, err = s.BroadcastCommand(req.Context(), request.Command)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	body, _ := json.Marshal(map[string]int64{"receivers": receivers})
+	fmt.Fprintln(w, string(body))
+}
+
+func parseCommand(cmd string) (*command, error) {
+	name, args, _ := strings.Cut(cmd, ":")
+
+	switch name {
+	case COMMAND_QUIET, COMMAND_STOP, COMMAND_DUMP_BUSY:
+		if args != "" {
+			return nil, fmt.Errorf("command %s takes no arguments", name)
+		}
+		return &command{name: name}, nil
+	case COMMAND_PAUSE, COMMAND_RESUME:
+		if args == "" {
+			return nil, fmt.Errorf("command %s requires a queue", name)
+		}
+		return &command{name: name, queue: args}, nil
+	case COMMAND_SET_CONCURRENCY:
+		// Queue names may contain colons, the concurrency can't.
+		i := strings.LastIndex(args, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("command %s requires a queue and a concurrency", name)
+		}
+		n, err := strconv.Atoi(args[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid concurrency for command %s: %w", name, err)
+		}
+		return &command{name: name, queue: args[:i], concurrency: n}, nil
+	}
+
+	return nil, fmt.Errorf("unknown command %q", cmd)
+}
+
+func (c *controller) start(ctx context.Context) {
+	s := c.server
+	c.pubsub = s.config.Client.Subscribe(ctx, s.controlChannel(s.config.processId), s.broadcastChannel())
+
+	go (func(messages <-chan *redis.Message) {
+		for message := range messages {
+			c.handle(message.Payload)
+		}
+	})(c.pubsub.Channel())
+}
+
+func (c *controller) quit() {
+	if err := c.pubsub.Close(); err != nil {
+		Logger.Errorln("failed to close control channel", err)
+	}
+}
+
+func (c *controller) handle(payload string) {
+	cmd, err := parseCommand(payload)
+	if err != nil {
+		Logger.Errorln("ignoring control command:", err)
+		return
+	}
+
+	Logger.Infoln("received control command", payload)
+
+	s := c.server
+
+	switch cmd.name {
+	case COMMAND_QUIET:
+		s.Quiet()
+	case COMMAND_STOP:
+		// Quit closes the control channel, don't wait for it here.
+		go s.Quit()
+	case COMMAND_PAUSE:
+		err = s.PauseQueueLocally(cmd.queue)
+	case COMMAND_RESUME:
+		err = s.ResumeQueueLocally(cmd.queue)
+	case COMMAND_SET_CONCURRENCY:
+		err = s.SetConcurrency(cmd.queue, cmd.concurrency)
+	case COMMAND_DUMP_BUSY:
+		s.dumpBusy()
+	}
+
+	if err != nil {
+		Logger.Errorln("failed to run control command", payload, ":", err)
+	}
+}
+
+// dumpBusy logs the jobs being processed by this process
+func (s *Server) dumpBusy() {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	for _, m := range s.managers {
+		for _, w := range m.currentWorkers() {
+			if message, startedAt := w.current(); message != nil {
+				Logger.Infoln("busy on queue", m.queueName(), "since", startedAt, ":", message.ToJson())
+			}
+		}
+	}
+}
+
+func (s *Server) controlChannel(processID string) string {
+	return s.config.Namespace + CONTROL_CHANNEL + ":" + processID
+}
+
+func (s *Server) broadcastChannel() string {
+	return s.config.Namespace + CONTROL_CHANNEL
+}
+
+func newController(server *Server) *controller {
+	return &controller{server, nil}
+}
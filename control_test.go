@@ -0,0 +1,145 @@
+package workers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func ControlSpec(c gospec.Context) {
+	ctx := context.Background()
+	const queueName = "queue-control"
+
+	job := (func(message *Msg) {})
+
+	c.Specify("parseCommand", func() {
+		c.Specify("parses commands without arguments", func() {
+			cmd, err := parseCommand("quiet")
+			c.Expect(err, IsNil)
+			c.Expect(cmd.name, Equals, COMMAND_QUIET)
+		})
+
+		c.Specify("parses queue commands", func() {
+			cmd, err := parseCommand("pause:my:queue")
+			c.Expect(err, IsNil)
+			c.Expect(cmd.name, Equals, COMMAND_PAUSE)
+			c.Expect(cmd.queue, Equals, "my:queue")
+		})
+
+		c.Specify("parses set-concurrency", func() {
+			cmd, err := parseCommand("set-concurrency:my:queue:20")
+			c.Expect(err, IsNil)
+			c.Expect(cmd.queue, Equals, "my:queue")
+			c.Expect(cmd.concurrency, Equals, 20)
+		})
+
+		c.Specify("rejects invalid commands", func() {
+			for _, cmd := range []string{"reboot", "pause", "stop:now", "set-concurrency:queue", "set-concurrency:queue:many"} {
+				_, err := parseCommand(cmd)
+				c.Expect(err, Not(IsNil))
+			}
+		})
+	})
+
+	c.Specify("handle", func() {
+		Process(queueName, job, 1)
+		controller := newController(defaultServer)
+
+		c.Specify("pauses and resumes queues", func() {
+			controller.handle("pause:" + queueName)
+			c.Expect(defaultServer.managers[queueName].isPaused(), IsTrue)
+
+			controller.handle("resume:" + queueName)
+			c.Expect(defaultServer.managers[queueName].isPaused(), IsFalse)
+		})
+
+		c.Specify("sets concurrency", func() {
+			controller.handle("set-concurrency:" + queueName + ":4")
+			c.Expect(defaultServer.managers[queueName].concurrency, Equals, 4)
+		})
+
+		c.Specify("quiets every queue", func() {
+			Start()
+
+			controller.handle("quiet")
+			c.Expect(defaultServer.managers[queueName].fetch.Closed(), IsTrue)
+
+			Quit()
+		})
+
+		ResetManagers()
+	})
+
+	c.Specify("receives commands sent to this process or broadcasted", func() {
+		Process(queueName, job, 1)
+		Start()
+
+		// Wait for the subscription
+		time.Sleep(50 * time.Millisecond)
+
+		receivers, err := SendCommand(ctx, "1", "pause:"+queueName)
+		c.Expect(err, IsNil)
+		c.Expect(receivers, Equals, int64(1))
+
+		time.Sleep(50 * time.Millisecond)
+		c.Expect(defaultServer.managers[queueName].isPaused(), IsTrue)
+
+		BroadcastCommand(ctx, "resume:"+queueName)
+
+		time.Sleep(50 * time.Millisecond)
+		c.Expect(defaultServer.managers[queueName].isPaused(), IsFalse)
+
+		Quit()
+		ResetManagers()
+	})
+
+	c.Specify("Control", func() {
+		post := func(contentType, body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/control", strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			return req
+		}
+
+		c.Specify("publishes posted commands", func() {
+			req := post("application/json", "{\"process\":\"1\",\"command\":\"dump-busy\"}")
+			w := httptest.NewRecorder()
+
+			Control(w, req)
+
+			c.Expect(w.Code, Equals, http.StatusOK)
+			c.Expect(w.Body.String(), Equals, "{\"receivers\":0}\n")
+		})
+
+		c.Specify("rejects unknown commands", func() {
+			req := post("application/json; charset=utf-8", "{\"command\":\"reboot\"}")
+			w := httptest.NewRecorder()
+
+			Control(w, req)
+
+			c.Expect(w.Code, Equals, http.StatusBadRequest)
+		})
+
+		c.Specify("rejects forms", func() {
+			req := post("application/x-www-form-urlencoded", "command=stop")
+			w := httptest.NewRecorder()
+
+			Control(w, req)
+
+			c.Expect(w.Code, Equals, http.StatusUnsupportedMediaType)
+		})
+
+		c.Specify("requires POST", func() {
+			req := httptest.NewRequest(http.MethodGet, "/control?command=stop", nil)
+			w := httptest.NewRecorder()
+
+			Control(w, req)
+
+			c.Expect(w.Code, Equals, http.StatusMethodNotAllowed)
+		})
+	})
+}
@@ -0,0 +1,47 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DEFAULT_DEAD_MAX_JOBS = 10000
+	DEFAULT_DEAD_TIMEOUT  = 180 * 24 * 60 * 60 // 6 months
+)
+
+// DeadSet holds jobs which have exhausted their retries. It is stored in the
+// same format as Sidekiq's dead set.
+type DeadSet struct {
+	sortedSet
+}
+
+// NewDeadSet returns the dead set of the configured namespace
+func NewDeadSet() *DeadSet {
+	return defaultServer.DeadSet()
+}
+
+// DeadSet returns the dead set of the server's namespace
+func (s *Server) DeadSet() *DeadSet {
+	return &DeadSet{sortedSet{DEAD_KEY, s}}
+}
+
+// Kill adds a message to the dead set, trimming entries older than
+// Config.DeadTimeoutInSeconds and any beyond Config.DeadMaxJobs.
+func (d *DeadSet) Kill(ctx context.Context, message *Msg) error {
+	config := d.server.config
+	now := nowToSecondsWithNanoPrecision()
+
+	pipe := config.Client.TxPipeline()
+	pipe.ZAdd(ctx, d.key(), redis.Z{
+		Score:  now,
+		Member: message.ToJson(),
+	})
+	pipe.ZRemRangeByScore(ctx, d.key(), "-inf", fmt.Sprintf("%f", now-float64(config.DeadTimeoutInSeconds)))
+	pipe.ZRemRangeByRank(ctx, d.key(), 0, -int64(config.DeadMaxJobs)-1)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,126 @@
+package workers
+
+import (
+	"context"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+	"github.com/redis/go-redis/v9"
+)
+
+func DeadSetSpec(c gospec.Context) {
+	ctx := context.Background()
+
+	was := Config.Namespace
+	Config.Namespace = "prod:"
+
+	conn := Config.Client
+	dead := NewDeadSet()
+
+	c.Specify("Kill", func() {
+		c.Specify("adds message to the dead set", func() {
+			message, _ := NewMsg("{\"jid\":\"1\",\"queue\":\"default\"}")
+
+			err := dead.Kill(ctx, message)
+			c.Expect(err, IsNil)
+
+			entries, _ := conn.ZRange(ctx, "prod:"+DEAD_KEY, 0, -1).Result()
+			c.Expect(len(entries), Equals, 1)
+			c.Expect(entries[0], Equals, message.ToJson())
+		})
+
+		c.Specify("trims the oldest jobs beyond max size", func() {
+			Config.DeadMaxJobs = 2
+
+			for _, jid := range []string{"1", "2", "3"} {
+				message, _ := NewMsg("{\"jid\":\"" + jid + "\",\"queue\":\"default\"}")
+				dead.Kill(ctx, message)
+			}
+
+			entries, _ := dead.List(ctx, 0, -1)
+			c.Expect(len(entries), Equals, 2)
+			c.Expect(entries[0].Jid(), Equals, "3")
+			c.Expect(entries[1].Jid(), Equals, "2")
+		})
+
+		c.Specify("trims jobs older than the timeout", func() {
+			now := nowToSecondsWithNanoPrecision()
+			conn.ZAdd(ctx, "prod:"+DEAD_KEY, redis.Z{
+				Score:  now - float64(Config.DeadTimeoutInSeconds) - 60,
+				Member: "{\"jid\":\"old\",\"queue\":\"default\"}",
+			})
+
+			message, _ := NewMsg("{\"jid\":\"new\",\"queue\":\"default\"}")
+			dead.Kill(ctx, message)
+
+			entries, _ := dead.List(ctx, 0, -1)
+			c.Expect(len(entries), Equals, 1)
+			c.Expect(entries[0].Jid(), Equals, "new")
+		})
+	})
+
+	c.Specify("administration", func() {
+		message1, _ := NewMsg("{\"jid\":\"1\",\"queue\":\"default\",\"retry_count\":25}")
+		message2, _ := NewMsg("{\"jid\":\"2\",\"queue\":\"other\",\"retry_count\":25}")
+		dead.Kill(ctx, message1)
+		dead.Kill(ctx, message2)
+
+		c.Specify("lists jobs and size", func() {
+			size, _ := dead.Size(ctx)
+			c.Expect(size, Equals, int64(2))
+
+			entries, _ := dead.List(ctx, 0, 0)
+			c.Expect(len(entries), Equals, 1)
+			c.Expect(entries[0].Jid(), Equals, "2")
+		})
+
+		c.Specify("retries a job on its queue", func() {
+			entries, _ := dead.List(ctx, 0, -1)
+
+			err := dead.Retry(ctx, entries[0])
+			c.Expect(err, IsNil)
+
+			size, _ := dead.Size(ctx)
+			c.Expect(size, Equals, int64(1))
+
+			queued, _ := conn.LRange(ctx, "prod:queue:other", 0, -1).Result()
+			c.Expect(len(queued), Equals, 1)
+
+			message, _ := NewMsg(queued[0])
+			retryCount, _ := message.Get("retry_count").Int()
+			c.Expect(message.Jid(), Equals, "2")
+			c.Expect(retryCount, Equals, 24)
+		})
+
+		c.Specify("retries all jobs", func() {
+			dead.RetryAll(ctx)
+
+			size, _ := dead.Size(ctx)
+			c.Expect(size, Equals, int64(0))
+
+			defaultCount, _ := conn.LLen(ctx, "prod:queue:default").Result()
+			otherCount, _ := conn.LLen(ctx, "prod:queue:other").Result()
+			c.Expect(defaultCount, Equals, int64(1))
+			c.Expect(otherCount, Equals, int64(1))
+		})
+
+		c.Specify("deletes a job", func() {
+			entries, _ := dead.List(ctx, 0, -1)
+
+			dead.Delete(ctx, entries[1])
+
+			entries, _ = dead.List(ctx, 0, -1)
+			c.Expect(len(entries), Equals, 1)
+			c.Expect(entries[0].Jid(), Equals, "2")
+		})
+
+		c.Specify("clears the set", func() {
+			dead.Clear(ctx)
+
+			size, _ := dead.Size(ctx)
+			c.Expect(size, Equals, int64(0))
+		})
+	})
+
+	Config.Namespace = was
+}
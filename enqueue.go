@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	"io"
+	"sync"
 	"time"
 )
 
@@ -29,6 +30,16 @@ type EnqueueOptions struct {
 	RetryMax     int          `json:"retry_max,omitempty"`
 	At           float64      `json:"at,omitempty"`
 	RetryOptions RetryOptions `json:"retry_options,omitempty"`
+	Backoff      Backoff      `json:"backoff,omitempty"`
+	Backtrace    int          `json:"backtrace,omitempty"`
+	// ExpiresAt skips the job when it hasn't run by then, in seconds since
+	// the epoch like At.
+	ExpiresAt float64 `json:"expires_at,omitempty"`
+	// Partition runs the job after the jobs enqueued before it with the same
+	// partition key in the queue, one at a time, e.g. a user ID.
+	Partition string `json:"partition,omitempty"`
+	// TrackStatus records the status of the job, queried with JobStatus
+	TrackStatus bool `json:"track_status,omitempty"`
 }
 
 type RetryOptions struct {
@@ -48,19 +59,60 @@ func generateJid() string {
 	return fmt.Sprintf("%x", b)
 }
 
+// Client enqueues jobs for the servers of one redis configuration
+type Client struct {
+	config    *WorkerConfig
+	hooksM    sync.RWMutex
+	onEnqueue []JobHook
+}
+
+// defaultClient backs the package-level enqueue functions. Its configuration
+// is set by Configure.
+var defaultClient = newClient(nil)
+
+// NewClient returns a client for the given options. Unlike New, it doesn't
+// require a ProcessID.
+func NewClient(options Options) *Client {
+	return newClient(newClientConfig(options))
+}
+
+func newClient(config *WorkerConfig) *Client {
+	return &Client{config: config}
+}
+
 func Enqueue(queue, class string, args interface{}) (string, error) {
-	return EnqueueWithOptions(queue, class, args, EnqueueOptions{At: nowToSecondsWithNanoPrecision()})
+	return defaultClient.Enqueue(queue, class, args)
 }
 
 func EnqueueIn(queue, class string, in float64, args interface{}) (string, error) {
-	return EnqueueWithOptions(queue, class, args, EnqueueOptions{At: nowToSecondsWithNanoPrecision() + in})
+	return defaultClient.EnqueueIn(queue, class, in, args)
 }
 
 func EnqueueAt(queue, class string, at time.Time, args interface{}) (string, error) {
-	return EnqueueWithOptions(queue, class, args, EnqueueOptions{At: timeToSecondsWithNanoPrecision(at)})
+	return defaultClient.EnqueueAt(queue, class, at, args)
 }
 
 func EnqueueWithOptions(queue, class string, args interface{}, opts EnqueueOptions) (string, error) {
+	return defaultClient.EnqueueWithOptions(queue, class, args, opts)
+}
+
+func (c *Client) Enqueue(queue, class string, args interface{}) (string, error) {
+	return c.EnqueueWithOptions(queue, class, args, EnqueueOptions{At: nowToSecondsWithNanoPrecision()})
+}
+
+func (c *Client) EnqueueIn(queue, class string, in float64, args interface{}) (string, error) {
+	return c.EnqueueWithOptions(queue, class, args, EnqueueOptions{At: nowToSecondsWithNanoPrecision() + in})
+}
+
+func (c *Client) EnqueueAt(queue, class string, at time.Time, args interface{}) (string, error) {
+	return c.EnqueueWithOptions(queue, class, args, EnqueueOptions{At: timeToSecondsWithNanoPrecision(at)})
+}
+
+func (c *Client) EnqueueWithOptions(queue, class string, args interface{}, opts EnqueueOptions) (string, error) {
+	if err := checkBackoff(opts.Backoff); err != nil {
+		return "", err
+	}
+
 	now := nowToSecondsWithNanoPrecision()
 	ctx := context.Background()
 
@@ -78,20 +130,31 @@ func EnqueueWithOptions(queue, class string, args interface{}, opts EnqueueOptio
 		return "", err
 	}
 
+	// The job and its status are written in one transaction
+	pipe := c.config.Client.TxPipeline()
+
+	if opts.TrackStatus {
+		state := STATUS_QUEUED
+		if now < opts.At {
+			state = STATUS_SCHEDULED
+		}
+		c.trackEnqueued(ctx, pipe, bytes, state)
+	}
+
 	if now < opts.At {
-		err := enqueueAt(ctx, data.At, bytes)
+		c.enqueueAt(ctx, pipe, data.At, bytes)
+		_, err := pipe.Exec(ctx)
+		c.runEnqueueHooks(queue, bytes, err)
 		return data.Jid, err
 	}
 
-	conn := Config.Client
-
-	_, err = conn.SAdd(ctx, Config.Namespace+"queues", queue).Result()
-	if err != nil {
-		return "", err
+	if opts.Partition != "" {
+		c.pushPartitioned(ctx, pipe, queue, data.Jid, opts.Partition, bytes)
+	} else {
+		c.push(ctx, pipe, queue, bytes)
 	}
-
-	queue = Config.Namespace + "queue:" + queue
-	_, err = conn.LPush(ctx, queue, bytes).Result()
+	_, err = pipe.Exec(ctx)
+	c.runEnqueueHooks(queue, bytes, err)
 	if err != nil {
 		return "", err
 	}
@@ -99,20 +162,23 @@ func EnqueueWithOptions(queue, class string, args interface{}, opts EnqueueOptio
 	return data.Jid, nil
 }
 
-func enqueueAt(ctx context.Context, at float64, bytes []byte) error {
-	conn := Config.Client
+func (c *Client) push(ctx context.Context, pipe redis.Pipeliner, queue string, bytes []byte) {
+	pipe.SAdd(ctx, c.config.Namespace+"queues", queue)
+	pipe.LPush(ctx, c.config.Namespace+"queue:"+queue, bytes)
+}
+
+func (c *Client) pushPartitioned(ctx context.Context, pipe redis.Pipeliner, queue, jid, partition string, bytes []byte) {
+	pipe.SAdd(ctx, c.config.Namespace+"queues", queue)
+	pushPartitioned(ctx, pipe, c.config, queue, partition, jid, string(bytes))
+}
 
+func (c *Client) enqueueAt(ctx context.Context, pipe redis.Pipeliner, at float64, bytes []byte) {
 	zItem := redis.Z{
 		Score:  at,
 		Member: bytes,
 	}
 
-	_, err := conn.ZAdd(ctx, Config.Namespace+SCHEDULED_JOBS_KEY, zItem).Result()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	pipe.ZAdd(ctx, c.config.Namespace+SCHEDULED_JOBS_KEY, zItem)
 }
 
 func timeToSecondsWithNanoPrecision(t time.Time) float64 {
@@ -3,6 +3,7 @@ package workers
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/customerio/gospec"
 	. "github.com/customerio/gospec"
@@ -122,6 +123,78 @@ func EnqueueSpec(c gospec.Context) {
 		})
 	})
 
+	c.Specify("has backoff when set", func() {
+		conn := Config.Client
+
+		EnqueueWithOptions(
+			"enqueue8", "Compare", []string{"foo", "bar"},
+			EnqueueOptions{
+				Retry:   true,
+				Backoff: ScheduleBackoff{[]time.Duration{10 * time.Second, time.Minute}},
+			})
+
+		strResult, _ := conn.LPop(ctx, "prod:queue:enqueue8").Result()
+		var result map[string]interface{}
+		json.Unmarshal([]byte(strResult), &result)
+
+		backoff := result["backoff"].(map[string]interface{})
+		c.Expect(backoff["type"], Equals, "schedule")
+		c.Expect(len(backoff["delays"].([]interface{})), Equals, 2)
+	})
+
+	c.Specify("fails with custom backoffs", func() {
+		_, err := EnqueueWithOptions(
+			"enqueue8", "Compare", []string{"foo", "bar"},
+			EnqueueOptions{
+				Retry:   true,
+				Backoff: customBackoff{},
+			})
+
+		c.Expect(err, Not(IsNil))
+
+		count, _ := Config.Client.LLen(ctx, "prod:queue:enqueue8").Result()
+		c.Expect(count, Equals, int64(0))
+	})
+
+	c.Specify("runs enqueue hooks", func() {
+		var enqueuedJid, enqueuedQueue string
+		OnEnqueue(func(queue string, message *Msg, err error, duration time.Duration) {
+			enqueuedJid = message.Jid()
+			enqueuedQueue = queue
+		})
+
+		jid, _ := Enqueue("enqueue9", "Add", []int{1, 2})
+
+		c.Expect(enqueuedJid, Equals, jid)
+		c.Expect(enqueuedQueue, Equals, "enqueue9")
+
+		defaultClient.onEnqueue = nil
+	})
+
+	c.Specify("enqueues jobs whose enqueue hooks panic", func() {
+		OnEnqueue(func(queue string, message *Msg, err error, duration time.Duration) {
+			panic("AHHHH")
+		})
+
+		jid, err := Enqueue("enqueue9", "Add", []int{1, 2})
+
+		c.Expect(err, IsNil)
+		c.Expect(jid, Not(Equals), "")
+
+		defaultClient.onEnqueue = nil
+	})
+
+	c.Specify("stores the expiration of jobs", func() {
+		conn := Config.Client
+
+		EnqueueWithOptions("enqueue10", "Add", []int{1, 2}, EnqueueOptions{ExpiresAt: 1700000000})
+
+		strResult, _ := conn.LPop(ctx, "prod:queue:enqueue10").Result()
+		message, _ := NewMsg(strResult)
+
+		c.Expect(message.Get("expires_at").MustFloat64(), Equals, float64(1700000000))
+	})
+
 	c.Specify("EnqueueIn", func() {
 		scheduleQueue := "prod:" + SCHEDULED_JOBS_KEY
 		conn := Config.Client
@@ -152,3 +225,9 @@ func EnqueueSpec(c gospec.Context) {
 
 	Config.Namespace = was
 }
+
+type customBackoff struct{}
+
+func (customBackoff) Delay(retryCount int) time.Duration {
+	return time.Minute
+}
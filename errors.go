@@ -0,0 +1,126 @@
+package workers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type permanentError struct {
+	err error
+}
+
+type retryInError struct {
+	delay time.Duration
+	err   error
+}
+
+type discardError struct {
+	err error
+}
+
+// Permanent wraps an error which retrying won't fix. A job panicking with it
+// skips its remaining retries and goes straight to the dead set.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// RetryIn wraps an error to retry the job after delay instead of the delay
+// computed by its backoff, e.g. from a rate limit's Retry-After.
+func RetryIn(delay time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryInError{delay, err}
+}
+
+// Discard wraps an error to acknowledge the job without retrying it or
+// recording it as failed.
+func Discard(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &discardError{err}
+}
+
+// JobFailedError is returned for the result of a job which failed for good.
+// State is the state it ended in: failed, dead, expired or discarded.
+type JobFailedError struct {
+	Jid     string
+	State   string
+	Message string
+}
+
+func (e *JobFailedError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("job %s %s", e.Jid, e.State)
+	}
+	return fmt.Sprintf("job %s %s: %s", e.Jid, e.State, e.Message)
+}
+
+// ShutdownError tells that workers gave up waiting for jobs on shutdown.
+// Requeued jobs were pushed back to their queue, as they failed once
+// cancelled or were still running. Running jobs were still running; their
+// outcome no longer counts once they return.
+type ShutdownError struct {
+	Requeued []string
+	Running  []string
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("shutdown timeout expired, requeued %d jobs: %v, %d still running: %v", len(e.Requeued), e.Requeued, len(e.Running), e.Running)
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func (e *retryInError) Error() string { return e.err.Error() }
+func (e *retryInError) Unwrap() error { return e.err }
+
+func (e *discardError) Error() string { return e.err.Error() }
+func (e *discardError) Unwrap() error { return e.err }
+
+func isPermanent(e interface{}) bool {
+	var target *permanentError
+	err, ok := e.(error)
+	return ok && errors.As(err, &target)
+}
+
+func isDiscarded(e interface{}) bool {
+	var target *discardError
+	err, ok := e.(error)
+	return ok && errors.As(err, &target)
+}
+
+func retryInDelay(e interface{}) (time.Duration, bool) {
+	var target *retryInError
+	if err, ok := e.(error); ok && errors.As(err, &target) {
+		return target.delay, true
+	}
+	return 0, false
+}
+
+func panicToError(e interface{}) error {
+	if err, ok := e.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", e)
+}
+
+// errorClass returns the type of the error at the root of the chain the job
+// panicked with, or the type of the panic value.
+func errorClass(e interface{}) string {
+	err, ok := e.(error)
+	if !ok {
+		return fmt.Sprintf("%T", e)
+	}
+
+	for next := errors.Unwrap(err); next != nil; next = errors.Unwrap(err) {
+		err = next
+	}
+
+	return fmt.Sprintf("%T", err)
+}
@@ -2,13 +2,15 @@ package workers
 
 import (
 	"context"
+	"time"
 
 	"github.com/customerio/gospec"
 	. "github.com/customerio/gospec"
+	"github.com/redis/go-redis/v9"
 )
 
 func buildFetch(queue string) Fetcher {
-	manager := newManager(queue, nil, 1)
+	manager := defaultServer.newManager(queue, nil, 1)
 	fetch := manager.fetch
 	go fetch.Fetch()
 	return fetch
@@ -27,6 +29,10 @@ func FetchSpec(c gospec.Context) {
 	c.Specify("Fetch", func() {
 		message, _ := NewMsg("{\"foo\":\"bar\"}")
 
+		// Pushes while a pop is pending need their own connection
+		pusher := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+		defer pusher.Close()
+
 		c.Specify("it puts messages from the queues on the messages channel", func() {
 			fetch := buildFetch("fetchQueue2")
 
@@ -104,6 +110,45 @@ func FetchSpec(c gospec.Context) {
 			fetch.Close()
 		})
 
+		c.Specify("pushes back messages popped once closed", func() {
+			fetch := buildFetch("fetchQueue7")
+
+			conn := Config.Client
+
+			// The pop waits for a message
+			fetch.Ready() <- true
+			fetch.Close()
+
+			pusher.LPush(ctx, "queue:fetchQueue7", message.ToJson())
+
+			len := int64(0)
+			for i := 0; i < 50 && len == 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+				len, _ = conn.LLen(ctx, "queue:fetchQueue7").Result()
+			}
+			c.Expect(len, Equals, int64(1))
+
+			inprogress, _ := conn.LLen(ctx, "queue:fetchQueue7:1:inprogress").Result()
+			c.Expect(inprogress, Equals, int64(0))
+		})
+
+		c.Specify("hands messages popped once closed to draining workers", func() {
+			fetcher := buildFetch("fetchQueue8")
+
+			conn := Config.Client
+
+			fetcher.Ready() <- true
+			drained := make(chan bool)
+			fetcher.(*fetch).drain(drained)
+
+			pusher.LPush(ctx, "queue:fetchQueue8", message.ToJson())
+			c.Expect(<-fetcher.Messages(), Equals, message)
+			close(drained)
+
+			inprogress, _ := conn.LLen(ctx, "queue:fetchQueue8:1:inprogress").Result()
+			c.Expect(inprogress, Equals, int64(1))
+		})
+
 		c.Specify("refires any messages left in progress from prior instance", func() {
 			message2, _ := NewMsg("{\"foo\":\"bar2\"}")
 			message3, _ := NewMsg("{\"foo\":\"bar3\"}")
@@ -2,8 +2,8 @@ package workers
 
 import (
 	"context"
-	"fmt"
 	"github.com/redis/go-redis/v9"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,6 +19,7 @@ type Fetcher interface {
 }
 
 type fetch struct {
+	config       *WorkerConfig
 	queue        string
 	ready        chan bool
 	finishedwork chan bool
@@ -26,10 +27,19 @@ type fetch struct {
 	stop         chan bool
 	exit         chan bool
 	closed       chan bool
+	failures     int64
+	// drained is closed once the workers quit, when they drain the messages
+	// popped after Close rather than leaving them to their queue.
+	drained chan bool
 }
 
 func NewFetch(queue string, messages chan *Msg, ready chan bool) Fetcher {
+	return newFetch(Config, queue, messages, ready)
+}
+
+func newFetch(config *WorkerConfig, queue string, messages chan *Msg, ready chan bool) Fetcher {
 	return &fetch{
+		config,
 		queue,
 		ready,
 		make(chan bool),
@@ -37,6 +47,8 @@ func NewFetch(queue string, messages chan *Msg, ready chan bool) Fetcher {
 		make(chan bool),
 		make(chan bool),
 		make(chan bool),
+		0,
+		nil,
 	}
 }
 
@@ -54,7 +66,7 @@ func (f *fetch) processOldMessages(ctx context.Context) {
 }
 
 func (f *fetch) Fetch() {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
 	f.processOldMessages(ctx)
 
 	go func() {
@@ -71,7 +83,18 @@ func (f *fetch) Fetch() {
 	for {
 		select {
 		case <-f.stop:
-			// Stop the redis-polling goroutine
+			// Stop the redis-polling goroutine. Cancelling only drops a
+			// pop still waiting for a connection: the reply of one already
+			// sent is still read, and its message goes back to its queue.
+			// Draining workers still take the messages popped meanwhile.
+			if f.drained != nil {
+				go func() {
+					<-f.drained
+					cancel()
+				}()
+			} else {
+				cancel()
+			}
 			close(f.closed)
 			// Signal to Close() that the fetcher has stopped
 			close(f.exit)
@@ -81,13 +104,15 @@ func (f *fetch) Fetch() {
 }
 
 func (f *fetch) tryFetchMessage(ctx context.Context) {
-	conn := Config.Client
+	conn := f.config.Client
 
 	message, err := conn.BLMove(ctx, f.queue, f.inprogressQueue(), "right", "left", 1*time.Second).Result()
 	if err != nil {
-		// If redis returns null, the queue is empty. Just ignore the error.
-		if err.Error() != redis.Nil.Error() {
+		// If redis returns null, the queue is empty. Just ignore the error,
+		// as well as the one of a pop dropped on Close.
+		if err.Error() != redis.Nil.Error() && ctx.Err() == nil {
 			Logger.Errorln("failed to fetch message", err)
+			atomic.AddInt64(&f.failures, 1)
 			time.Sleep(1 * time.Second)
 		}
 	} else {
@@ -103,16 +128,61 @@ func (f *fetch) sendMessage(message string) {
 		return
 	}
 
-	f.Messages() <- msg
+	if !f.Closed() {
+		select {
+		case f.Messages() <- msg:
+			return
+		case <-f.closed:
+		}
+	}
+
+	// Once closed, e.g. by a pop pending on Close, a message no worker took
+	// goes back to its queue, unless the workers drain it before they quit.
+	if f.drained != nil {
+		select {
+		case f.Messages() <- msg:
+			return
+		case <-f.drained:
+		}
+	}
+
+	f.giveBack(message)
+}
+
+// giveBack moves a message no worker took from in progress back to the head
+// of its queue.
+func (f *fetch) giveBack(message string) {
+	ctx := context.Background()
+
+	pipe := f.config.Client.TxPipeline()
+	pipe.LRem(ctx, f.inprogressQueue(), -1, message)
+	pipe.RPush(ctx, f.queue, message)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		Logger.Errorln("failed to push back message fetched on close", message, ":", err)
+	}
+}
+
+// drain closes the fetcher of workers about to quit. The messages popped
+// meanwhile are still handed to them, until drained is closed once they
+// quit.
+func (f *fetch) drain(drained chan bool) {
+	f.drained = drained
+	f.Close()
 }
 
 func (f *fetch) Acknowledge(message *Msg) {
 	ctx := context.Background()
-	conn := Config.Client
+	conn := f.config.Client
 
 	conn.LRem(ctx, f.inprogressQueue(), -1, message.OriginalJson())
 }
 
+// fetchErrors returns how many times fetching from the queue failed
+func (f *fetch) fetchErrors() int64 {
+	return atomic.LoadInt64(&f.failures)
+}
+
 func (f *fetch) Messages() chan *Msg {
 	return f.messages
 }
@@ -140,7 +210,7 @@ func (f *fetch) Closed() bool {
 }
 
 func (f *fetch) inprogressMessages(ctx context.Context) []string {
-	conn := Config.Client
+	conn := f.config.Client
 
 	messages, err := conn.LRange(ctx, f.inprogressQueue(), 0, -1).Result()
 	if err != nil {
@@ -151,5 +221,5 @@ func (f *fetch) inprogressMessages(ctx context.Context) []string {
 }
 
 func (f *fetch) inprogressQueue() string {
-	return fmt.Sprint(f.queue, ":", Config.processId, ":inprogress")
+	return f.config.inprogressQueue(f.queue)
 }
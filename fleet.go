@@ -0,0 +1,66 @@
+package workers
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// fleetPoller applies the settings shared by every process through redis:
+// concurrency overrides and paused queues.
+type fleetPoller struct {
+	server *Server
+	closed chan bool
+}
+
+func (f *fleetPoller) start(ctx context.Context) {
+	go (func() {
+		for {
+			select {
+			case <-f.closed:
+				return
+			default:
+			}
+
+			f.poll(ctx)
+
+			time.Sleep(time.Duration(f.server.config.PoolInterval) * time.Second)
+		}
+	})()
+}
+
+func (f *fleetPoller) quit() {
+	close(f.closed)
+}
+
+func (f *fleetPoller) poll(ctx context.Context) {
+	config := f.server.config
+
+	pipe := config.Client.Pipeline()
+	overridesCmd := pipe.HGetAll(ctx, config.Namespace+CONCURRENCY_KEY)
+	pausedCmd := pipe.SMembers(ctx, config.Namespace+PAUSED_KEY)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		Logger.Errorln("failed to fetch fleet settings", err)
+		return
+	}
+
+	overrides := overridesCmd.Val()
+	paused := make(map[string]bool)
+	for _, queue := range pausedCmd.Val() {
+		paused[queue] = true
+	}
+
+	f.server.access.Lock()
+	defer f.server.access.Unlock()
+
+	for queue, m := range f.server.managers {
+		override, _ := strconv.Atoi(overrides[queue])
+		m.setOverride(override)
+		m.setPaused(pausedByFleet, paused[queue])
+	}
+}
+
+func newFleetPoller(server *Server) *fleetPoller {
+	return &fleetPoller{server, make(chan bool)}
+}
@@ -0,0 +1,105 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+// Phases of a server, readable without taking access, which Quit holds while
+// draining.
+const (
+	phaseStopped int32 = iota
+	phaseRunning
+	phaseDraining
+)
+
+type health struct {
+	Healthy   bool            `json:"healthy"`
+	Ready     bool            `json:"ready"`
+	Running   bool            `json:"running"`
+	Draining  bool            `json:"draining"`
+	Redis     bool            `json:"redis"`
+	Scheduler bool            `json:"scheduler"`
+	Fetching  map[string]bool `json:"fetching"`
+	Failures  []string        `json:"failures"`
+}
+
+// Healthz reports whether the process is alive: redis is reachable and,
+// unless draining, every queue is being fetched and the scheduler is polling.
+func Healthz(w http.ResponseWriter, req *http.Request) {
+	defaultServer.Healthz(w, req)
+}
+
+// Readyz reports whether the process should receive work: it is healthy and
+// neither quiet nor quitting.
+func Readyz(w http.ResponseWriter, req *http.Request) {
+	defaultServer.Readyz(w, req)
+}
+
+// Healthz reports whether the server is alive
+func (s *Server) Healthz(w http.ResponseWriter, req *http.Request) {
+	h := s.checkHealth(req.Context())
+	writeHealth(w, h, h.Healthy)
+}
+
+// Readyz reports whether the server should receive work
+func (s *Server) Readyz(w http.ResponseWriter, req *http.Request) {
+	h := s.checkHealth(req.Context())
+	writeHealth(w, h, h.Ready)
+}
+
+func (s *Server) checkHealth(ctx context.Context) *health {
+	phase := atomic.LoadInt32(&s.phase)
+
+	h := &health{
+		Running:  phase != phaseStopped,
+		Draining: phase == phaseDraining,
+		Redis:    true,
+		Fetching: make(map[string]bool),
+		Failures: make([]string, 0),
+	}
+
+	if err := s.config.Client.Ping(ctx).Err(); err != nil {
+		h.Redis = false
+		h.Failures = append(h.Failures, fmt.Sprint("redis is unreachable: ", err))
+	}
+
+	for queue, m := range s.currentManagers() {
+		fetching := !m.fetcher().Closed()
+		h.Fetching[queue] = fetching
+
+		if phase == phaseRunning && !fetching {
+			h.Failures = append(h.Failures, fmt.Sprint("queue ", queue, " is not being fetched"))
+		}
+	}
+
+	s.stateM.RLock()
+	h.Scheduler = s.schedule != nil && s.schedule.polling()
+	s.stateM.RUnlock()
+
+	if phase == phaseRunning && !h.Scheduler {
+		h.Failures = append(h.Failures, "scheduler is not polling")
+	}
+
+	sort.Strings(h.Failures)
+
+	h.Healthy = len(h.Failures) == 0
+	h.Ready = h.Healthy && phase == phaseRunning
+
+	return h
+}
+
+func writeHealth(w http.ResponseWriter, h *health, ok bool) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	body, _ := json.MarshalIndent(h, "", "  ")
+	fmt.Fprintln(w, string(body))
+}
@@ -0,0 +1,93 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+	"github.com/redis/go-redis/v9"
+)
+
+func HealthSpec(c gospec.Context) {
+	const queueName = "queue-health"
+
+	check := func(handler http.HandlerFunc) (int, *health) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		h := &health{}
+		json.Unmarshal(w.Body.Bytes(), h)
+		return w.Code, h
+	}
+
+	c.Specify("is healthy and ready while running", func() {
+		Process(queueName, myJob, 1)
+		Start()
+
+		code, h := check(Healthz)
+		c.Expect(code, Equals, http.StatusOK)
+		c.Expect(h.Redis, IsTrue)
+		c.Expect(h.Scheduler, IsTrue)
+		c.Expect(h.Fetching[queueName], IsTrue)
+
+		code, h = check(Readyz)
+		c.Expect(code, Equals, http.StatusOK)
+		c.Expect(h.Ready, IsTrue)
+
+		Quit()
+	})
+
+	c.Specify("is not ready before starting", func() {
+		code, _ := check(Healthz)
+		c.Expect(code, Equals, http.StatusOK)
+
+		code, h := check(Readyz)
+		c.Expect(code, Equals, http.StatusServiceUnavailable)
+		c.Expect(h.Running, IsFalse)
+	})
+
+	c.Specify("stays healthy but is not ready while quiet", func() {
+		Process(queueName, myJob, 1)
+		Start()
+		Quiet()
+
+		code, h := check(Healthz)
+		c.Expect(code, Equals, http.StatusOK)
+		c.Expect(h.Fetching[queueName], IsFalse)
+
+		code, h = check(Readyz)
+		c.Expect(code, Equals, http.StatusServiceUnavailable)
+		c.Expect(h.Draining, IsTrue)
+
+		Quit()
+	})
+
+	c.Specify("is not ready as soon as quitting starts", func() {
+		var code int
+		BeforeQuit(func() {
+			code, _ = check(Readyz)
+		})
+
+		Start()
+		Quit()
+
+		c.Expect(code, Equals, http.StatusServiceUnavailable)
+
+		defaultServer.beforeQuit = nil
+	})
+
+	c.Specify("is unhealthy when redis is unreachable", func() {
+		server := New(Options{
+			RedisClient: redis.NewClient(&redis.Options{Addr: "localhost:1"}),
+			ProcessID:   "1",
+		})
+
+		h := server.checkHealth(context.Background())
+		c.Expect(h.Healthy, IsFalse)
+		c.Expect(h.Redis, IsFalse)
+		c.Expect(len(h.Failures), Equals, 1)
+	})
+}
@@ -1,22 +1,199 @@
 package workers
 
-var beforeStart []func()
-var duringDrain []func()
+import "time"
 
+// JobHook is called on an event of a job with its queue, its message, the
+// error it failed with if any, and how long it ran, zero for events
+// happening before it runs.
+type JobHook func(queue string, message *Msg, err error, duration time.Duration)
+
+type jobEvent int
+
+const (
+	jobStarted jobEvent = iota
+	jobSucceeded
+	jobFailed
+	jobRetried
+	jobDied
+	jobExpired
+	jobDiscarded
+	// jobProcessed has no hooks: metrics count it for jobs which succeeded,
+	// failed or were discarded.
+	jobProcessed
+)
+
+// BeforeStart registers a function called when starting, before workers
+// start. It runs with the server locked, so it must not call back into it,
+// e.g. with Process or Quit.
 func BeforeStart(f func()) {
-	access.Lock()
-	defer access.Unlock()
-	beforeStart = append(beforeStart, f)
+	defaultServer.BeforeStart(f)
+}
+
+// AfterStart registers a function called once workers are started
+func AfterStart(f func()) {
+	defaultServer.AfterStart(f)
 }
 
-// func AfterStart
-// func BeforeQuit
-// func AfterQuit
+// BeforeQuit registers a function called when quitting, before fetching
+// stops. As with BeforeStart, it must not call back into the server.
+func BeforeQuit(f func()) {
+	defaultServer.BeforeQuit(f)
+}
 
+// DuringDrain registers a function called when quitting, once fetching
+// stopped and while in-flight jobs drain. As with BeforeStart, it must not
+// call back into the server.
 func DuringDrain(f func()) {
-	access.Lock()
-	defer access.Unlock()
-	duringDrain = append(duringDrain, f)
+	defaultServer.DuringDrain(f)
+}
+
+// AfterQuit registers a function called once in-flight jobs are drained
+func AfterQuit(f func()) {
+	defaultServer.AfterQuit(f)
+}
+
+// OnEnqueue registers a function called when a job is enqueued, with the
+// error enqueuing it failed with if any.
+func OnEnqueue(f JobHook) {
+	defaultClient.OnEnqueue(f)
+}
+
+// OnStart registers a function called before a job runs
+func OnStart(f JobHook) {
+	defaultServer.OnStart(f)
+}
+
+// OnSuccess registers a function called when a job completes
+func OnSuccess(f JobHook) {
+	defaultServer.OnSuccess(f)
+}
+
+// OnFailure registers a function called when a job fails, after the retry
+// or death hooks.
+func OnFailure(f JobHook) {
+	defaultServer.OnFailure(f)
+}
+
+// OnRetry registers a function called when a failed job is scheduled for a
+// retry.
+func OnRetry(f JobHook) {
+	defaultServer.OnRetry(f)
+}
+
+// OnDeath registers a function called when a job exhausts its retries and
+// is moved to the dead set.
+func OnDeath(f JobHook) {
+	defaultServer.OnDeath(f)
+}
+
+// OnExpire registers a function called when a job is skipped because it
+// expired before running.
+func OnExpire(f JobHook) {
+	defaultServer.OnExpire(f)
+}
+
+// OnDiscard registers a function called when a job fails with Discard, with
+// the error it was discarded with.
+func OnDiscard(f JobHook) {
+	defaultServer.OnDiscard(f)
+}
+
+func (s *Server) BeforeStart(f func()) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.beforeStart = append(s.beforeStart, f)
+}
+
+func (s *Server) AfterStart(f func()) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.afterStart = append(s.afterStart, f)
+}
+
+func (s *Server) BeforeQuit(f func()) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.beforeQuit = append(s.beforeQuit, f)
+}
+
+func (s *Server) DuringDrain(f func()) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.duringDrain = append(s.duringDrain, f)
+}
+
+func (s *Server) AfterQuit(f func()) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.afterQuit = append(s.afterQuit, f)
+}
+
+func (s *Server) OnStart(f JobHook)   { s.addJobHook(jobStarted, f) }
+func (s *Server) OnSuccess(f JobHook) { s.addJobHook(jobSucceeded, f) }
+func (s *Server) OnFailure(f JobHook) { s.addJobHook(jobFailed, f) }
+func (s *Server) OnRetry(f JobHook)   { s.addJobHook(jobRetried, f) }
+func (s *Server) OnDeath(f JobHook)   { s.addJobHook(jobDied, f) }
+func (s *Server) OnExpire(f JobHook)  { s.addJobHook(jobExpired, f) }
+func (s *Server) OnDiscard(f JobHook) { s.addJobHook(jobDiscarded, f) }
+
+// Job hooks run on worker goroutines, which can't take access while Quit
+// holds it to drain them.
+func (s *Server) addJobHook(event jobEvent, f JobHook) {
+	s.hooksM.Lock()
+	defer s.hooksM.Unlock()
+	s.jobHooks[event] = append(s.jobHooks[event], f)
+}
+
+func (s *Server) runJobHooks(event jobEvent, queue string, message *Msg, err error, duration time.Duration) {
+	s.trackStatus(event, message, err)
+	s.settleResult(event, message, err)
+	s.metrics.record(event, queue, message, duration)
+
+	s.hooksM.RLock()
+	hooks := s.jobHooks[event]
+	s.hooksM.RUnlock()
+
+	for _, f := range hooks {
+		runJobHook(f, queue, message, err, duration)
+	}
+}
+
+// runJobHook calls a job hook, logging its panic rather than failing the job
+// it was called for.
+func runJobHook(f JobHook, queue string, message *Msg, err error, duration time.Duration) {
+	defer func() {
+		if e := recover(); e != nil {
+			Logger.Errorln("job hook of", message.Jid(), "panicked:", e)
+		}
+	}()
+
+	f(queue, message, err, duration)
+}
+
+// OnEnqueue registers a function called when the client enqueues a job
+func (c *Client) OnEnqueue(f JobHook) {
+	c.hooksM.Lock()
+	defer c.hooksM.Unlock()
+	c.onEnqueue = append(c.onEnqueue, f)
+}
+
+func (c *Client) runEnqueueHooks(queue string, bytes []byte, err error) {
+	c.hooksM.RLock()
+	hooks := c.onEnqueue
+	c.hooksM.RUnlock()
+
+	if len(hooks) == 0 {
+		return
+	}
+
+	message, msgErr := NewMsg(string(bytes))
+	if msgErr != nil {
+		return
+	}
+
+	for _, f := range hooks {
+		runJobHook(f, queue, message, err, 0)
+	}
 }
 
 func runHooks(hooks []func()) {
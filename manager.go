@@ -1,56 +1,181 @@
 package workers
 
 import (
+	"context"
 	"strings"
 	"sync"
 )
 
+// pauseSource tells who paused a manager. A manager resumes once no source
+// keeps it paused.
+type pauseSource int
+
+const (
+	pausedLocally pauseSource = 1 << iota
+	pausedByFleet
+)
+
 type manager struct {
+	server      *Server
 	queue       string
 	fetch       Fetcher
 	job         jobFunc
 	concurrency int
+	override    int
+	autoscaled  int
+	running     bool
+	autoscaler  *autoscaler
+	paused      pauseSource
+	resume      chan bool
+	pauseM      *sync.Mutex
 	workers     []*worker
 	workersM    *sync.Mutex
+	retiring    *sync.WaitGroup
+	requeued    []string
+	requeuedM   *sync.Mutex
 	confirm     chan *Msg
 	stop        chan bool
 	exit        chan bool
 	mids        *Middlewares
+	ctx         context.Context
+	cancel      context.CancelFunc
 	*sync.WaitGroup
 }
 
 func (m *manager) start() {
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.requeuedM.Lock()
+	m.requeued = nil
+	m.requeuedM.Unlock()
 	m.Add(1)
 	m.loadWorkers()
 	go m.manage()
+
+	if m.autoscaler != nil {
+		m.autoscaler.start()
+	}
 }
 
 func (m *manager) prepare() {
-	if !m.fetch.Closed() {
-		m.fetch.Close()
+	if fetch := m.fetcher(); !fetch.Closed() {
+		fetch.Close()
 	}
 }
 
 func (m *manager) quit() {
 	Logger.Infoln("quitting queue", m.queueName(), "(waiting for", m.processing(), "/", len(m.workers), "workers).")
-	m.prepare()
 
+	// The workers still take the messages popped until they quit
+	drained := make(chan bool)
+	if f, ok := m.fetcher().(*fetch); ok && !f.Closed() {
+		f.drain(drained)
+	} else {
+		m.prepare()
+	}
+
+	if m.autoscaler != nil {
+		m.autoscaler.quit()
+	}
+
+	// Stop scaling, and wait for the workers without holding workersM, as
+	// workers take it to requeue interrupted jobs.
 	m.workersM.Lock()
-	for _, worker := range m.workers {
+	m.running = false
+	workers := append([]*worker(nil), m.workers...)
+	m.workersM.Unlock()
+
+	for _, worker := range workers {
 		worker.quit()
 	}
-	m.workersM.Unlock()
+
+	// Retired workers still confirm their last message
+	m.retiring.Wait()
+	close(drained)
 
 	m.stop <- true
 	<-m.exit
 
 	m.reset()
+	m.cancel()
 
 	m.Done()
 }
 
+// abandoning tells whether the shutdown timeout expired, cancelling the
+// context of the jobs of the manager.
+func (m *manager) abandoning() bool {
+	return m.ctx != nil && m.ctx.Err() != nil
+}
+
+// requeue pushes the message of a job interrupted on shutdown from the
+// in-progress queue back to the front of the queue, once its worker is done
+// with it.
+func (m *manager) requeue(message *Msg) {
+	ctx := context.Background()
+
+	pipe := m.server.config.Client.TxPipeline()
+	pipe.LRem(ctx, m.server.config.inprogressQueue(m.queue), -1, message.OriginalJson())
+	pipe.RPush(ctx, m.queue, message.OriginalJson())
+	if message.tracked() {
+		queueStatus(ctx, pipe, m.server.config, message, STATUS_QUEUED)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		Logger.Errorln("failed to requeue interrupted job", message.Jid(), "of", m.queueName(), ":", err)
+		return
+	}
+
+	m.requeuedM.Lock()
+	m.requeued = append(m.requeued, message.Jid())
+	m.requeuedM.Unlock()
+}
+
+// abandon pushes the messages of the jobs still running at the shutdown
+// deadline back to their queue, as they may never return. It returns the
+// JIDs of the jobs requeued on shutdown, and of the ones still running.
+func (m *manager) abandon() (requeued []string, running []string) {
+	for _, w := range m.currentWorkers() {
+		if message, _ := w.current(); message != nil {
+			running = append(running, message.Jid())
+			if message.abandon() {
+				m.requeue(message)
+			}
+		}
+	}
+
+	m.requeuedM.Lock()
+	defer m.requeuedM.Unlock()
+	return append([]string(nil), m.requeued...), running
+}
+
+// renew returns a manager for the queue of m with its settings, to take
+// over while m still waits for jobs abandoned on shutdown.
+func (m *manager) renew(queue string) *manager {
+	renewed := m.server.newManager(queue, m.job, 0)
+	renewed.mids = m.mids
+
+	m.workersM.Lock()
+	renewed.concurrency = m.concurrency
+	renewed.override = m.override
+	renewed.autoscaled = m.autoscaled
+	m.workersM.Unlock()
+
+	if m.autoscaler != nil {
+		renewed.autoscaler = newAutoscaler(renewed, m.autoscaler.options)
+	}
+
+	m.pauseM.Lock()
+	if m.paused != 0 {
+		renewed.paused = m.paused
+		renewed.resume = make(chan bool)
+	}
+	m.pauseM.Unlock()
+
+	return renewed
+}
+
 func (m *manager) manage() {
-	Logger.Infoln("processing queue", m.queueName(), "with", m.concurrency, "workers.")
+	Logger.Infoln("processing queue", m.queueName(), "with", m.workerCount(), "workers.")
 
 	go m.fetch.Fetch()
 
@@ -67,11 +192,115 @@ func (m *manager) manage() {
 
 func (m *manager) loadWorkers() {
 	m.workersM.Lock()
-	for i := 0; i < m.concurrency; i++ {
-		m.workers[i] = newWorker(m)
-		m.workers[i].start()
+	m.workers = make([]*worker, 0, m.target())
+	m.running = true
+	m.scale()
+	m.workersM.Unlock()
+}
+
+// setConcurrency changes the number of workers of the manager. It only
+// takes effect while no fleet-wide override is set.
+func (m *manager) setConcurrency(concurrency int) {
+	m.workersM.Lock()
+	m.concurrency = concurrency
+	m.scale()
+	m.workersM.Unlock()
+
+	Logger.Infoln("queue", m.queueName(), "concurrency set to", concurrency)
+}
+
+// setOverride sets the fleet-wide concurrency of the manager, 0 meaning
+// none.
+func (m *manager) setOverride(override int) {
+	m.workersM.Lock()
+	changed := m.override != override
+	if changed {
+		m.override = override
+		m.scale()
 	}
 	m.workersM.Unlock()
+
+	if changed {
+		Logger.Infoln("queue", m.queueName(), "fleet concurrency override set to", override)
+	}
+}
+
+// setAutoscaled sets the concurrency chosen by the autoscaler, 0 meaning
+// none. It takes precedence over the configured concurrency, which is kept
+// for when autoscaling stops.
+func (m *manager) setAutoscaled(autoscaled int) {
+	m.workersM.Lock()
+	changed := m.autoscaled != autoscaled
+	if changed {
+		m.autoscaled = autoscaled
+		m.scale()
+	}
+	m.workersM.Unlock()
+
+	if changed && autoscaled > 0 {
+		Logger.Infoln("queue", m.queueName(), "autoscaled to", autoscaled)
+	}
+}
+
+func (m *manager) overridden() bool {
+	m.workersM.Lock()
+	defer m.workersM.Unlock()
+	return m.override > 0
+}
+
+func (m *manager) target() int {
+	if m.override > 0 {
+		return m.override
+	}
+	if m.autoscaled > 0 {
+		return m.autoscaled
+	}
+	return m.concurrency
+}
+
+// scale starts or retires workers to match the target concurrency. Retired
+// workers finish their current message before exiting. It must be called
+// with workersM held.
+func (m *manager) scale() {
+	if !m.running {
+		return
+	}
+
+	target := m.target()
+	if target == len(m.workers) {
+		return
+	}
+
+	for len(m.workers) < target {
+		worker := newWorker(m)
+		worker.start()
+		m.workers = append(m.workers, worker)
+	}
+
+	if len(m.workers) > target {
+		retired := m.workers[target:]
+		m.workers = append([]*worker(nil), m.workers[:target]...)
+
+		for _, w := range retired {
+			m.retiring.Add(1)
+			go (func(w *worker) {
+				w.quit()
+				m.retiring.Done()
+			})(w)
+		}
+	}
+}
+
+func (m *manager) workerCount() int {
+	m.workersM.Lock()
+	defer m.workersM.Unlock()
+	return len(m.workers)
+}
+
+func (m *manager) currentWorkers() []*worker {
+	m.workersM.Lock()
+	defer m.workersM.Unlock()
+	return append([]*worker(nil), m.workers...)
 }
 
 func (m *manager) processing() (count int) {
@@ -85,39 +314,100 @@ func (m *manager) processing() (count int) {
 	return
 }
 
+// setPaused pauses or resumes the manager for a source. Paused workers stop
+// asking the fetcher for messages, so nothing new is pulled from the queue
+// while in-flight jobs finish.
+func (m *manager) setPaused(source pauseSource, paused bool) {
+	m.pauseM.Lock()
+	defer m.pauseM.Unlock()
+
+	was := m.paused != 0
+	if paused {
+		m.paused |= source
+	} else {
+		m.paused &^= source
+	}
+
+	if !was && m.paused != 0 {
+		m.resume = make(chan bool)
+		Logger.Infoln("paused queue", m.queueName())
+	} else if was && m.paused == 0 {
+		close(m.resume)
+		m.resume = nil
+		Logger.Infoln("resumed queue", m.queueName())
+	}
+}
+
+func (m *manager) isPaused() bool {
+	m.pauseM.Lock()
+	defer m.pauseM.Unlock()
+	return m.paused != 0
+}
+
+// resumed returns a channel closed once the manager resumes, or nil when it
+// isn't paused.
+func (m *manager) resumed() chan bool {
+	m.pauseM.Lock()
+	defer m.pauseM.Unlock()
+	return m.resume
+}
+
 func (m *manager) queueName() string {
 	return strings.Replace(m.queue, "queue:", "", 1)
 }
 
 func (m *manager) reset() {
-	m.fetch = Config.Fetch(m.queue)
+	m.workersM.Lock()
+	m.fetch = m.server.config.Fetch(m.queue)
+	m.workersM.Unlock()
 }
 
-func newManager(queue string, job jobFunc, concurrency int, mids ...Action) *manager {
+// fetcher returns the fetcher of the manager, for readers which may race
+// with reset replacing it.
+func (m *manager) fetcher() Fetcher {
+	m.workersM.Lock()
+	defer m.workersM.Unlock()
+	return m.fetch
+}
+
+func (s *Server) newManager(queue string, job jobFunc, concurrency int, mids ...Action) *manager {
 	var customMids *Middlewares
 	if len(mids) == 0 {
-		customMids = Middleware
+		customMids = s.middleware()
 	} else {
-		customMids = NewMiddleware(Middleware.actions...)
+		customMids = NewMiddleware(s.middleware().actions...)
 		for _, m := range mids {
 			customMids.Append(m)
 		}
 	}
 	m := &manager{
-		Config.Namespace + "queue:" + queue,
+		s,
+		s.config.Namespace + "queue:" + queue,
 		nil,
 		job,
 		concurrency,
-		make([]*worker, concurrency),
+		0,
+		0,
+		false,
+		nil,
+		0,
+		nil,
+		&sync.Mutex{},
+		make([]*worker, 0, concurrency),
+		&sync.Mutex{},
+		&sync.WaitGroup{},
+		nil,
 		&sync.Mutex{},
 		make(chan *Msg),
 		make(chan bool),
 		make(chan bool),
 		customMids,
+		nil,
+		nil,
 		&sync.WaitGroup{},
 	}
 
-	m.fetch = Config.Fetch(m.queue)
+	m.fetch = s.config.Fetch(m.queue)
 
 	return m
 }
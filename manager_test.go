@@ -50,28 +50,28 @@ func ManagerSpec(c gospec.Context) {
 
 	c.Specify("newManager", func() {
 		c.Specify("sets queue with namespace", func() {
-			manager := newManager(queueName, testJob, 10)
+			manager := defaultServer.newManager(queueName, testJob, 10)
 			c.Expect(manager.queue, Equals, fmt.Sprintf("prod:queue:%s", queueName))
 		})
 
 		c.Specify("sets job function", func() {
-			manager := newManager(queueName, testJob, 10)
+			manager := defaultServer.newManager(queueName, testJob, 10)
 			c.Expect(fmt.Sprintf("%p", manager.job), Equals, fmt.Sprintf("%p", testJob))
 		})
 
 		c.Specify("sets worker concurrency", func() {
-			manager := newManager(queueName, testJob, 10)
+			manager := defaultServer.newManager(queueName, testJob, 10)
 			c.Expect(manager.concurrency, Equals, 10)
 		})
 
 		c.Specify("no per-manager middleware means 'use global Middleware object'", func() {
-			manager := newManager(queueName, testJob, 10)
+			manager := defaultServer.newManager(queueName, testJob, 10)
 			c.Expect(manager.mids, Equals, Middleware)
 		})
 
 		c.Specify("per-manager middlewares create separate middleware chains", func() {
 			mid1 := customMid{Base: "0"}
-			manager := newManager(queueName, testJob, 10, &mid1)
+			manager := defaultServer.newManager(queueName, testJob, 10, &mid1)
 			c.Expect(manager.mids, Not(Equals), Middleware)
 			c.Expect(len(manager.mids.actions), Equals, len(Middleware.actions)+1)
 		})
@@ -85,7 +85,7 @@ func ManagerSpec(c gospec.Context) {
 		message2, _ := NewMsg("{\"foo\":\"bar2\",\"args\":[\"foo\",\"bar2\"]}")
 
 		c.Specify("coordinates processing of queue messages", func() {
-			manager := newManager("manager1", testJob, 10)
+			manager := defaultServer.newManager("manager1", testJob, 10)
 
 			conn.LPush(ctx, "prod:queue:manager1", message.ToJson())
 			conn.LPush(ctx, "prod:queue:manager1", message2.ToJson())
@@ -118,7 +118,7 @@ func ManagerSpec(c gospec.Context) {
 
 				time.Sleep(1 * time.Second)
 			})
-			manager := newManager("manager1", slowJob, 10)
+			manager := defaultServer.newManager("manager1", slowJob, 10)
 
 			for i := 0; i < 9; i++ {
 				conn.LPush(ctx, "prod:queue:manager1", message.ToJson())
@@ -148,9 +148,9 @@ func ManagerSpec(c gospec.Context) {
 			Middleware = NewMiddleware()
 			Middleware.Append(&mid1)
 
-			manager1 := newManager("manager1", testJob, 10)
-			manager2 := newManager("manager2", testJob, 10, &mid2)
-			manager3 := newManager("manager3", testJob, 10, &mid3)
+			manager1 := defaultServer.newManager("manager1", testJob, 10)
+			manager2 := defaultServer.newManager("manager2", testJob, 10, &mid2)
+			manager3 := defaultServer.newManager("manager3", testJob, 10, &mid3)
 
 			conn.LPush(ctx, "prod:queue:manager1", message.ToJson())
 			conn.LPush(ctx, "prod:queue:manager2", message.ToJson())
@@ -184,8 +184,66 @@ func ManagerSpec(c gospec.Context) {
 			manager3.quit()
 		})
 
+		c.Specify("scales workers while running", func() {
+			manager := defaultServer.newManager("manager1", testJob, 2)
+			manager.start()
+
+			manager.setConcurrency(5)
+			c.Expect(len(manager.workers), Equals, 5)
+
+			manager.setConcurrency(1)
+			c.Expect(len(manager.workers), Equals, 1)
+
+			conn.LPush(ctx, "prod:queue:manager1", message.ToJson())
+			c.Expect(<-processed, Equals, message.Args())
+
+			manager.quit()
+
+			len, _ := conn.LLen(ctx, "prod:queue:manager1:1:inprogress").Result()
+			c.Expect(len, Equals, int64(0))
+		})
+
+		c.Specify("retired workers finish their current message", func() {
+			started := make(chan bool)
+			slowJob := (func(message *Msg) {
+				started <- true
+				time.Sleep(500 * time.Millisecond)
+			})
+			manager := defaultServer.newManager("manager1", slowJob, 1)
+			manager.start()
+
+			conn.LPush(ctx, "prod:queue:manager1", message.ToJson())
+			<-started
+
+			manager.setOverride(2)
+			manager.setOverride(0)
+			manager.setConcurrency(3)
+			manager.setConcurrency(1)
+
+			manager.quit()
+
+			len, _ := conn.LLen(ctx, "prod:queue:manager1:1:inprogress").Result()
+			c.Expect(len, Equals, int64(0))
+		})
+
+		c.Specify("fleet override takes precedence over local concurrency", func() {
+			manager := defaultServer.newManager("manager1", testJob, 2)
+			manager.start()
+
+			manager.setOverride(4)
+			c.Expect(len(manager.workers), Equals, 4)
+
+			manager.setConcurrency(3)
+			c.Expect(len(manager.workers), Equals, 4)
+
+			manager.setOverride(0)
+			c.Expect(len(manager.workers), Equals, 3)
+
+			manager.quit()
+		})
+
 		c.Specify("prepare stops fetching new messages from queue", func() {
-			manager := newManager("manager2", testJob, 10)
+			manager := defaultServer.newManager("manager2", testJob, 10)
 			manager.start()
 
 			manager.prepare()
@@ -0,0 +1,253 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds of the job duration histogram, in
+// seconds.
+var durationBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+type jobLabels struct {
+	queue string
+	class string
+}
+
+type histogram struct {
+	buckets []int64
+	sum     float64
+	count   int64
+}
+
+// metrics counts the jobs of a server in this process since it started, as
+// Prometheus counters do.
+type metrics struct {
+	sync.Mutex
+	counts    map[jobEvent]map[jobLabels]int64
+	durations map[jobLabels]*histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		counts:    make(map[jobEvent]map[jobLabels]int64),
+		durations: make(map[jobLabels]*histogram),
+	}
+}
+
+// record counts a job event. Jobs which ran are also counted as processed,
+// and their duration observed, when they succeed, fail or are discarded.
+func (m *metrics) record(event jobEvent, queue string, message *Msg, duration time.Duration) {
+	class, _ := message.Get("class").String()
+	labels := jobLabels{queue, class}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.increment(event, labels)
+
+	if event == jobSucceeded || event == jobFailed || event == jobDiscarded {
+		m.increment(jobProcessed, labels)
+		m.observe(labels, duration)
+	}
+}
+
+func (m *metrics) increment(event jobEvent, labels jobLabels) {
+	if m.counts[event] == nil {
+		m.counts[event] = make(map[jobLabels]int64)
+	}
+	m.counts[event][labels]++
+}
+
+func (m *metrics) observe(labels jobLabels, duration time.Duration) {
+	h, ok := m.durations[labels]
+	if !ok {
+		h = &histogram{buckets: make([]int64, len(durationBuckets))}
+		m.durations[labels] = h
+	}
+
+	seconds := duration.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// Metrics writes the metrics of the default server in the Prometheus text
+// format.
+func Metrics(w http.ResponseWriter, req *http.Request) {
+	defaultServer.Metrics(w, req)
+}
+
+// Metrics writes the metrics of the server in the Prometheus text format:
+// job counters and durations of this process, and the state of its queues
+// and of the retry, schedule and dead sets.
+func (s *Server) Metrics(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	s.writeJobMetrics(w)
+	s.writeQueueMetrics(req.Context(), w)
+}
+
+var jobCounters = []struct {
+	event jobEvent
+	name  string
+	help  string
+}{
+	{jobProcessed, "workers_jobs_processed_total", "Jobs which ran, whether they succeeded, failed or were discarded."},
+	{jobFailed, "workers_jobs_failed_total", "Jobs which failed."},
+	{jobRetried, "workers_jobs_retried_total", "Failed jobs scheduled for a retry."},
+	{jobDied, "workers_jobs_dead_total", "Jobs moved to the dead set."},
+}
+
+func (s *Server) writeJobMetrics(w io.Writer) {
+	s.metrics.Lock()
+	defer s.metrics.Unlock()
+
+	for _, counter := range jobCounters {
+		writeHeader(w, counter.name, "counter", counter.help)
+
+		counts := s.metrics.counts[counter.event]
+		labels := make([]jobLabels, 0, len(counts))
+		for l := range counts {
+			labels = append(labels, l)
+		}
+
+		for _, l := range sortLabels(labels) {
+			writeSample(w, counter.name, l.pairs(), float64(counts[l]))
+		}
+	}
+
+	name := "workers_job_duration_seconds"
+	writeHeader(w, name, "histogram", "How long jobs ran.")
+
+	labels := make([]jobLabels, 0, len(s.metrics.durations))
+	for l := range s.metrics.durations {
+		labels = append(labels, l)
+	}
+
+	for _, l := range sortLabels(labels) {
+		h := s.metrics.durations[l]
+		for i, bound := range durationBuckets {
+			writeSample(w, name+"_bucket", append(l.pairs(), "le", fmt.Sprint(bound)), float64(h.buckets[i]))
+		}
+		writeSample(w, name+"_bucket", append(l.pairs(), "le", "+Inf"), float64(h.count))
+		writeSample(w, name+"_sum", l.pairs(), h.sum)
+		writeSample(w, name+"_count", l.pairs(), float64(h.count))
+	}
+}
+
+func (s *Server) writeQueueMetrics(ctx context.Context, w io.Writer) {
+	managers := s.currentManagers()
+
+	queues := make([]string, 0, len(managers))
+	for queue := range managers {
+		queues = append(queues, queue)
+	}
+	sort.Strings(queues)
+
+	conn := s.config.Client
+
+	queueMetrics := []struct {
+		name  string
+		kind  string
+		help  string
+		value func(m *manager) (float64, bool)
+	}{
+		{"workers_queue_depth", "gauge", "Jobs waiting in the queue.", func(m *manager) (float64, bool) {
+			depth, err := conn.LLen(ctx, m.queue).Result()
+			return float64(depth), err == nil
+		}},
+		{"workers_queue_latency_seconds", "gauge", "How long the next job of the queue has been waiting.", func(m *manager) (float64, bool) {
+			latency, err := s.queueLatency(ctx, m.queue)
+			return latency.Seconds(), err == nil
+		}},
+		{"workers_busy_workers", "gauge", "Workers running a job.", func(m *manager) (float64, bool) {
+			return float64(m.processing()), true
+		}},
+		{"workers_workers", "gauge", "Workers of the queue.", func(m *manager) (float64, bool) {
+			return float64(m.workerCount()), true
+		}},
+		{"workers_fetch_errors_total", "counter", "Errors fetching jobs from the queue.", func(m *manager) (float64, bool) {
+			f, ok := m.fetcher().(interface{ fetchErrors() int64 })
+			if !ok {
+				return 0, false
+			}
+			return float64(f.fetchErrors()), true
+		}},
+	}
+
+	for _, metric := range queueMetrics {
+		writeHeader(w, metric.name, metric.kind, metric.help)
+		for _, queue := range queues {
+			if value, ok := metric.value(managers[queue]); ok {
+				writeSample(w, metric.name, []string{"queue", queue}, value)
+			}
+		}
+	}
+
+	sets := []struct {
+		name string
+		key  string
+		help string
+	}{
+		{"workers_retry_set_size", RETRY_KEY, "Jobs waiting for a retry."},
+		{"workers_scheduled_set_size", SCHEDULED_JOBS_KEY, "Jobs scheduled to run later."},
+		{"workers_dead_set_size", DEAD_KEY, "Jobs in the dead set."},
+	}
+
+	for _, set := range sets {
+		size, err := conn.ZCard(ctx, s.config.Namespace+set.key).Result()
+		if err != nil {
+			Logger.Errorln("failed to retrieve size of", set.key, ":", err)
+			continue
+		}
+
+		writeHeader(w, set.name, "gauge", set.help)
+		writeSample(w, set.name, nil, float64(size))
+	}
+}
+
+func (l jobLabels) pairs() []string {
+	return []string{"queue", l.queue, "class", l.class}
+}
+
+func sortLabels(labels []jobLabels) []jobLabels {
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].queue != labels[j].queue {
+			return labels[i].queue < labels[j].queue
+		}
+		return labels[i].class < labels[j].class
+	})
+
+	return labels
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeSample writes a sample with labels given as name, value pairs
+func writeSample(w io.Writer, name string, labels []string, value float64) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+labelEscaper.Replace(labels[i+1])+`"`)
+	}
+
+	if len(pairs) > 0 {
+		name += "{" + strings.Join(pairs, ",") + "}"
+	}
+
+	fmt.Fprintln(w, name, value)
+}
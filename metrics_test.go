@@ -0,0 +1,73 @@
+package workers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func MetricsSpec(c gospec.Context) {
+	const queueName = "queue-metrics"
+
+	defaultServer.metrics = newMetrics()
+
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		Metrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return w.Body.String()
+	}
+
+	contains := func(body string, line string) bool {
+		return strings.Contains(body, line+"\n")
+	}
+
+	c.Specify("counts jobs per queue and class", func() {
+		worker := newWorker(defaultServer.newManager(queueName, func(message *Msg) {
+			if message.Args().MustArray()[0] == "fail" {
+				panic("AHHHH")
+			}
+		}, 1))
+
+		succeeded, _ := NewMsg("{\"jid\":\"1\",\"class\":\"Add\",\"args\":[\"ok\"]}")
+		failed, _ := NewMsg("{\"jid\":\"2\",\"class\":\"Add\",\"args\":[\"fail\"],\"retry\":true}")
+		worker.process(succeeded)
+		worker.process(failed)
+
+		body := scrape()
+		labels := `{queue="queue-metrics",class="Add"}`
+
+		c.Expect(contains(body, "# TYPE workers_jobs_processed_total counter"), IsTrue)
+		c.Expect(contains(body, "workers_jobs_processed_total"+labels+" 2"), IsTrue)
+		c.Expect(contains(body, "workers_jobs_failed_total"+labels+" 1"), IsTrue)
+		c.Expect(contains(body, "workers_jobs_retried_total"+labels+" 1"), IsTrue)
+		c.Expect(contains(body, "workers_job_duration_seconds_count"+labels+" 2"), IsTrue)
+		c.Expect(contains(body, `workers_job_duration_seconds_bucket{queue="queue-metrics",class="Add",le="+Inf"} 2`), IsTrue)
+		c.Expect(contains(body, "workers_retry_set_size 1"), IsTrue)
+	})
+
+	c.Specify("reports the state of queues", func() {
+		Process(queueName, myJob, 3)
+		Enqueue(queueName, "Add", []int{1, 2})
+		Enqueue(queueName, "Add", []int{1, 2})
+
+		body := scrape()
+
+		c.Expect(contains(body, `workers_queue_depth{queue="queue-metrics"} 2`), IsTrue)
+		c.Expect(contains(body, `workers_busy_workers{queue="queue-metrics"} 0`), IsTrue)
+		c.Expect(contains(body, `workers_fetch_errors_total{queue="queue-metrics"} 0`), IsTrue)
+		c.Expect(strings.Contains(body, `workers_queue_latency_seconds{queue="queue-metrics"}`), IsTrue)
+		c.Expect(contains(body, "workers_scheduled_set_size 0"), IsTrue)
+		c.Expect(contains(body, "workers_dead_set_size 0"), IsTrue)
+	})
+
+	c.Specify("escapes label values", func() {
+		var b bytes.Buffer
+		writeSample(&b, "metric", []string{"class", "A\"b\\c\nd"}, 1)
+
+		c.Expect(b.String(), Equals, "metric{class=\"A\\\"b\\\\c\\nd\"} 1\n")
+	})
+}
@@ -7,12 +7,15 @@ import (
 	"github.com/redis/go-redis/v9"
 	"math"
 	"math/rand"
+	"runtime"
+	"strings"
 	"time"
 )
 
 const (
-	DEFAULT_MAX_RETRY = 25
-	LAYOUT            = "2006-01-02 15:04:05 MST"
+	DEFAULT_MAX_RETRY   = 25
+	MAX_BACKTRACE_LINES = 100
+	LAYOUT              = "2006-01-02 15:04:05 MST"
 )
 
 type MiddlewareRetry struct{}
@@ -20,31 +23,62 @@ type MiddlewareRetry struct{}
 func (r *MiddlewareRetry) Call(queue string, message *Msg, next func() bool) (acknowledge bool) {
 	defer func() {
 		if e := recover(); e != nil {
+			// Discarded jobs are acknowledged without a retry, and
+			// the worker reports them as such.
+			if isDiscarded(e) {
+				message.discarded = panicToError(e)
+				acknowledge = true
+				return
+			}
+
+			// Jobs pushed back at the shutdown deadline run again
+			// rather than being retried.
+			if message.abandoned() {
+				panic(e)
+			}
+
 			ctx := context.Background()
-			conn := Config.Client
-			if retry(message) {
-				message.Set("queue", queue)
-				message.Set("error_message", fmt.Sprintf("%v", e))
+			server := serverOf(message)
+			conn := server.config.Client
+			if !isPermanent(e) && retry(message) {
+				setError(queue, message, e)
 				retryCount := incrementRetry(message)
 
-				retryOptions, _ := message.Get("retry_options").Map()
-				waitDuration := durationToSecondsWithNanoPrecision(
-					time.Duration(
-						secondsToDelay(retryCount, retryOptions),
-					) * time.Second,
-				)
+				delay, ok := retryInDelay(e)
+				if !ok {
+					delay = retryDelay(queue, message, retryCount)
+				}
+				waitDuration := durationToSecondsWithNanoPrecision(delay)
 
 				zItem := redis.Z{
 					Score:  nowToSecondsWithNanoPrecision() + waitDuration,
 					Member: message.ToJson(),
 				}
 
-				_, err := conn.ZAdd(ctx, Config.Namespace+RETRY_KEY, zItem).Result()
+				_, err := conn.ZAdd(ctx, server.config.Namespace+RETRY_KEY, zItem).Result()
 				// If we can't add the job to the retry queue,
 				// then we shouldn't acknowledge the job, otherwise
 				// it'll disappear into the void.
 				if err != nil {
 					acknowledge = false
+				} else {
+					message.deferred = true
+					server.runJobHooks(jobRetried, queue, message, panicToError(e), message.elapsed())
+				}
+			} else if kill(message, isPermanent(e)) {
+				setError(queue, message, e)
+				if _, ok := message.CheckGet("failed_at"); !ok {
+					message.Set("failed_at", time.Now().UTC().Format(LAYOUT))
+				}
+
+				// As with retries, a job we failed to store
+				// must not be acknowledged.
+				if err := server.DeadSet().Kill(ctx, message); err != nil {
+					Logger.Errorln("failed to move job to dead set", message.Jid(), ":", err)
+					acknowledge = false
+				} else {
+					message.dead = true
+					server.runJobHooks(jobDied, queue, message, panicToError(e), message.elapsed())
 				}
 			}
 
@@ -58,8 +92,29 @@ func (r *MiddlewareRetry) Call(queue string, message *Msg, next func() bool) (ac
 }
 
 func retry(message *Msg) bool {
-	retry := false
-	max := DEFAULT_MAX_RETRY
+	retry, max := retryMax(message)
+
+	count, _ := message.Get("retry_count").Int()
+
+	return retry && count < max
+}
+
+// kill reports whether a message which can't be retried anymore belongs
+// in the dead set: only jobs which had retries enabled or failed with a
+// permanent error are kept, unless they opted out with "dead": false, as
+// in sidekiq.
+func kill(message *Msg, permanent bool) bool {
+	retry, _ := retryMax(message)
+
+	if dead, err := message.Get("dead").Bool(); err == nil && !dead {
+		return false
+	}
+
+	return retry || permanent
+}
+
+func retryMax(message *Msg) (retry bool, max int) {
+	max = DEFAULT_MAX_RETRY
 
 	if param, err := message.Get("retry").Bool(); err == nil {
 		retry = param
@@ -72,9 +127,73 @@ func retry(message *Msg) bool {
 		max = param
 	}
 
-	count, _ := message.Get("retry_count").Int()
+	return
+}
 
-	return retry && count < max
+// setError records the failure of a message in sidekiq's fields. It must be
+// called while panicking, so the backtrace includes the failing frames.
+func setError(queue string, message *Msg, e interface{}) {
+	message.Set("queue", queue)
+	message.Set("error_message", fmt.Sprintf("%v", e))
+	message.Set("error_class", errorClass(e))
+
+	if lines := backtraceLines(message); lines > 0 {
+		message.Set("error_backtrace", backtrace(lines))
+	}
+}
+
+// backtraceLines returns how many lines of backtrace to keep, from the
+// "backtrace" option which is either a count or true to keep all of them.
+func backtraceLines(message *Msg) int {
+	if param, err := message.Get("backtrace").Bool(); err == nil {
+		if param {
+			return MAX_BACKTRACE_LINES
+		}
+		return 0
+	}
+
+	lines, _ := message.Get("backtrace").Int()
+	if lines > MAX_BACKTRACE_LINES {
+		return MAX_BACKTRACE_LINES
+	}
+	return lines
+}
+
+// backtrace returns the stack of the current panic, starting at the frame
+// which panicked.
+func backtrace(lines int) []string {
+	pcs := make([]uintptr, 128)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(0, pcs)])
+
+	var stack []runtime.Frame
+	start := 0
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, frame)
+
+		// Middlewares re-panic, so start after the deepest panic,
+		// which is the one raised by the job.
+		if frame.Function == "runtime.gopanic" {
+			start = len(stack)
+		}
+		if !more {
+			break
+		}
+	}
+
+	trace := make([]string, 0, lines)
+	for _, frame := range stack[start:] {
+		// Skip runtime frames of panics such as nil dereferences.
+		if len(trace) == 0 && strings.HasPrefix(frame.Function, "runtime.") {
+			continue
+		}
+		if len(trace) == lines {
+			break
+		}
+		trace = append(trace, fmt.Sprintf("%s:%d:in `%s'", frame.File, frame.Line, frame.Function))
+	}
+
+	return trace
 }
 
 func incrementRetry(message *Msg) (retryCount int) {
@@ -92,6 +211,15 @@ func incrementRetry(message *Msg) (retryCount int) {
 	return
 }
 
+func retryDelay(queue string, message *Msg, retryCount int) time.Duration {
+	if backoff := serverOf(message).backoffFor(queue, message); backoff != nil {
+		return backoff.Delay(retryCount)
+	}
+
+	retryOptions, _ := message.Get("retry_options").Map()
+	return time.Duration(secondsToDelay(retryCount, retryOptions)) * time.Second
+}
+
 func secondsToDelay(count int, retryOptions map[string]interface{}) int {
 	exp := float64(4)
 	minDelay := float64(15)
@@ -2,6 +2,10 @@ package workers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/customerio/gospec"
@@ -21,7 +25,7 @@ func MiddlewareRetrySpec(c gospec.Context) {
 	)
 
 	layout := "2006-01-02 15:04:05 MST"
-	manager := newManager(queueName, panicingJob, 1)
+	manager := defaultServer.newManager(queueName, panicingJob, 1)
 	worker := newWorker(manager)
 
 	was := Config.Namespace
@@ -100,12 +104,45 @@ func MiddlewareRetrySpec(c gospec.Context) {
 
 		c.Expect(queue, Equals, queueName)
 		c.Expect(error_message, Equals, "AHHHH")
-		c.Expect(error_class, Equals, "")
+		c.Expect(error_class, Equals, "string")
 		c.Expect(retry_count, Equals, 0)
 		c.Expect(error_backtrace, Equals, "")
 		c.Expect(failed_at, Equals, time.Now().UTC().Format(layout))
 	})
 
+	c.Specify("records the root error class", func() {
+		manager := defaultServer.newManager(queueName, func(message *Msg) {
+			panic(Permanent(fmt.Errorf("saving user: %w", &json.SyntaxError{})))
+		}, 1)
+		worker := newWorker(manager)
+		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
+
+		worker.process(message)
+
+		conn := Config.Client
+
+		dead, _ := conn.ZRange(ctx, "prod:"+DEAD_KEY, 0, -1).Result()
+		message, _ = NewMsg(dead[0])
+
+		error_class, _ := message.Get("error_class").String()
+		c.Expect(error_class, Equals, "*json.SyntaxError")
+	})
+
+	c.Specify("records backtrace when requested", func() {
+		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true,\"backtrace\":3}")
+
+		worker.process(message)
+
+		conn := Config.Client
+
+		retries, _ := conn.ZRange(ctx, "prod:"+RETRY_KEY, 0, 1).Result()
+		message, _ = NewMsg(retries[0])
+
+		error_backtrace, _ := message.Get("error_backtrace").StringArray()
+		c.Expect(len(error_backtrace), Equals, 3)
+		c.Expect(error_backtrace[0], Satisfies, strings.Contains(error_backtrace[0], "MiddlewareRetrySpec"))
+	})
+
 	c.Specify("handles recurring failed message", func() {
 		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true,\"queue\":\"default\",\"error_message\":\"bam\",\"failed_at\":\"2013-07-20 14:03:42 UTC\",\"retry_count\":10}")
 
@@ -182,6 +219,93 @@ func MiddlewareRetrySpec(c gospec.Context) {
 		c.Expect(count, Equals, int64(0))
 	})
 
+	c.Specify("moves messages to dead set after retries are exhausted", func() {
+		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true,\"retry_max\":3,\"retry_count\":3}")
+
+		wares.call(queueName, message, func() {
+			worker.process(message)
+		})
+
+		conn := Config.Client
+
+		dead, _ := conn.ZRange(ctx, "prod:"+DEAD_KEY, 0, -1).Result()
+		c.Expect(len(dead), Equals, 1)
+
+		message, _ = NewMsg(dead[0])
+		queue, _ := message.Get("queue").String()
+		error_message, _ := message.Get("error_message").String()
+
+		c.Expect(queue, Equals, queueName)
+		c.Expect(error_message, Equals, "AHHHH")
+	})
+
+	c.Specify("doesn't move messages without retries to dead set", func() {
+		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":false}")
+
+		wares.call(queueName, message, func() {
+			worker.process(message)
+		})
+
+		conn := Config.Client
+
+		count, _ := conn.ZCard(ctx, "prod:"+DEAD_KEY).Result()
+		c.Expect(count, Equals, int64(0))
+	})
+
+	c.Specify("allows opting out of the dead set", func() {
+		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true,\"dead\":false,\"retry_count\":25}")
+
+		wares.call(queueName, message, func() {
+			worker.process(message)
+		})
+
+		conn := Config.Client
+
+		count, _ := conn.ZCard(ctx, "prod:"+DEAD_KEY).Result()
+		c.Expect(count, Equals, int64(0))
+	})
+
+	c.Specify("runs death hooks", func() {
+		var deadJid, deadQueue, deadError string
+		OnDeath(func(queue string, message *Msg, err error, duration time.Duration) {
+			deadJid = message.Jid()
+			deadQueue = queue
+			deadError = err.Error()
+		})
+
+		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true,\"retry_count\":25}")
+
+		wares.call(queueName, message, func() {
+			worker.process(message)
+		})
+
+		c.Expect(deadJid, Equals, "2")
+		c.Expect(deadQueue, Equals, queueName)
+		c.Expect(deadError, Equals, "AHHHH")
+
+		// Clear out global hooks variable
+		defaultServer.jobHooks = make(map[jobEvent][]JobHook)
+	})
+
+	c.Specify("runs retry hooks", func() {
+		var retriedJid, retryError string
+		OnRetry(func(queue string, message *Msg, err error, duration time.Duration) {
+			retriedJid = message.Jid()
+			retryError = err.Error()
+		})
+
+		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
+
+		wares.call(queueName, message, func() {
+			worker.process(message)
+		})
+
+		c.Expect(retriedJid, Equals, "2")
+		c.Expect(retryError, Equals, "AHHHH")
+
+		defaultServer.jobHooks = make(map[jobEvent][]JobHook)
+	})
+
 	c.Specify("use retry_options when provided - min_delay", func() {
 		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true,\"retry_options\":{\"exp\":2,\"min_delay\":1200,\"max_rand\":0}}")
 		var now int
@@ -255,5 +379,111 @@ func MiddlewareRetrySpec(c gospec.Context) {
 		c.Expect(nextAt, Satisfies, nextAt >= float64(now+0))
 	})
 
+	c.Specify("uses backoff from the payload", func() {
+		message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true,\"retry_count\":1,\"backoff\":{\"type\":\"schedule\",\"delays\":[10,60,600]}}")
+		var now int
+		wares.call(queueName, message, func() {
+			worker.process(message)
+			now = int(time.Now().Unix())
+		})
+
+		conn := Config.Client
+
+		values, _ := conn.ZRangeWithScores(ctx, "prod:"+RETRY_KEY, 0, -1).Result()
+		nextAt := values[0].Score
+		c.Expect(int(nextAt), Equals, now+600)
+	})
+
+	c.Specify("uses backoff of the job class, then of the queue", func() {
+		SetQueueBackoff(queueName, ConstantBackoff{100 * time.Second})
+		SetClassBackoff("Slow", ConstantBackoff{1000 * time.Second})
+
+		message, _ := NewMsg("{\"jid\":\"2\",\"class\":\"Slow\",\"retry\":true}")
+		message2, _ := NewMsg("{\"jid\":\"3\",\"class\":\"Fast\",\"retry\":true}")
+		var now int
+		wares.call(queueName, message, func() {
+			worker.process(message)
+			worker.process(message2)
+			now = int(time.Now().Unix())
+		})
+
+		conn := Config.Client
+
+		values, _ := conn.ZRangeWithScores(ctx, "prod:"+RETRY_KEY, 0, -1).Result()
+		c.Expect(len(values), Equals, 2)
+		c.Expect(int(values[0].Score), Equals, now+100)
+		c.Expect(int(values[1].Score), Equals, now+1000)
+
+		defaultServer.queueBackoffs = make(map[string]Backoff)
+		defaultServer.classBackoffs = make(map[string]Backoff)
+	})
+
+	c.Specify("typed errors", func() {
+		conn := Config.Client
+
+		c.Specify("permanent errors skip retries and go to dead set", func() {
+			manager := defaultServer.newManager(queueName, func(message *Msg) {
+				panic(Permanent(errors.New("invalid email")))
+			}, 1)
+			worker := newWorker(manager)
+			message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
+
+			worker.process(message)
+
+			retries, _ := conn.ZCard(ctx, "prod:"+RETRY_KEY).Result()
+			dead, _ := conn.ZRange(ctx, "prod:"+DEAD_KEY, 0, -1).Result()
+			c.Expect(retries, Equals, int64(0))
+			c.Expect(len(dead), Equals, 1)
+
+			message, _ = NewMsg(dead[0])
+			error_message, _ := message.Get("error_message").String()
+			c.Expect(error_message, Equals, "invalid email")
+		})
+
+		c.Specify("retry in errors override the computed delay", func() {
+			manager := defaultServer.newManager(queueName, func(message *Msg) {
+				panic(RetryIn(42*time.Second, errors.New("rate limited")))
+			}, 1)
+			worker := newWorker(manager)
+			message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
+
+			worker.process(message)
+			now := int(time.Now().Unix())
+
+			values, _ := conn.ZRangeWithScores(ctx, "prod:"+RETRY_KEY, 0, -1).Result()
+			c.Expect(len(values), Equals, 1)
+			c.Expect(int(values[0].Score), Equals, now+42)
+		})
+
+		c.Specify("discarded errors are acknowledged without retrying", func() {
+			manager := defaultServer.newManager(queueName, func(message *Msg) {
+				panic(Discard(errors.New("no longer relevant")))
+			}, 1)
+			worker := newWorker(manager)
+			message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
+
+			var discarded error
+			succeeded := false
+			defaultServer.OnDiscard(func(queue string, message *Msg, err error, duration time.Duration) {
+				discarded = err
+			})
+			defaultServer.OnSuccess(func(queue string, message *Msg, err error, duration time.Duration) {
+				succeeded = true
+			})
+
+			acknowledge := worker.process(message)
+
+			defaultServer.jobHooks = make(map[jobEvent][]JobHook)
+
+			retries, _ := conn.ZCard(ctx, "prod:"+RETRY_KEY).Result()
+			dead, _ := conn.ZCard(ctx, "prod:"+DEAD_KEY).Result()
+			c.Expect(acknowledge, IsTrue)
+			c.Expect(discarded.Error(), Equals, "no longer relevant")
+			c.Expect(succeeded, IsFalse)
+			c.Expect(retries, Equals, int64(0))
+			c.Expect(dead, Equals, int64(0))
+		})
+	})
+
 	Config.Namespace = was
 }
@@ -2,36 +2,66 @@ package workers
 
 import (
 	"context"
+	"strings"
 	"time"
 )
 
+// STATS_DAY_LAYOUT formats the day of daily stats buckets, in UTC
+const STATS_DAY_LAYOUT = "2006-01-02"
+
 type MiddlewareStats struct{}
 
 func (l *MiddlewareStats) Call(queue string, message *Msg, next func() bool) (acknowledge bool) {
 	ctx := context.Background()
+	config := serverOf(message).config
 
 	defer func() {
 		if e := recover(); e != nil {
-			incrementStats(ctx, "failed")
+			if isDiscarded(e) {
+				incrementStats(ctx, config, "processed", queue, message)
+			} else {
+				incrementStats(ctx, config, "failed", queue, message)
+			}
 			panic(e)
 		}
 	}()
 
 	acknowledge = next()
 
-	incrementStats(ctx, "processed")
+	incrementStats(ctx, config, "processed", queue, message)
 
 	return
 }
 
-func incrementStats(ctx context.Context, metric string) {
-	conn := Config.Client
+// incrementStats increments the counters of a metric, in total and for
+// today, globally and in the hashes of the counters per queue and per class.
+func incrementStats(ctx context.Context, config *WorkerConfig, metric, queue string, message *Msg) {
+	conn := config.Client
 
-	today := time.Now().UTC().Format("2006-01-02")
+	today := time.Now().UTC().Format(STATS_DAY_LAYOUT)
+	key := config.Namespace + "stat:" + metric
 
 	pipe := conn.TxPipeline()
-	pipe.Incr(ctx, Config.Namespace+"stat:"+metric)
-	pipe.Incr(ctx, Config.Namespace+"stat:"+metric+":"+today)
+	pipe.Incr(ctx, key)
+	pipe.Incr(ctx, key+":"+today)
+
+	if config.DailyStatsExpiration > 0 {
+		pipe.Expire(ctx, key+":"+today, config.DailyStatsExpiration)
+	}
+
+	breakdowns := map[string]string{"queue": strings.TrimPrefix(queue, config.Namespace)}
+	if class, _ := message.Get("class").String(); class != "" {
+		breakdowns["class"] = class
+	}
+
+	for by, field := range breakdowns {
+		pipe.HIncrBy(ctx, key+":"+by, field, 1)
+		pipe.HIncrBy(ctx, key+":"+by+":"+today, field, 1)
+
+		if config.DailyStatsExpiration > 0 {
+			pipe.Expire(ctx, key+":"+by+":"+today, config.DailyStatsExpiration)
+		}
+	}
 
 	if _, err := pipe.Exec(ctx); err != nil {
 		Logger.Errorln("failed to save stats:", err)
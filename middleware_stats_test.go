@@ -2,6 +2,7 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"time"
 
@@ -18,7 +19,7 @@ func MiddlewareStatsSpec(c gospec.Context) {
 	})
 
 	layout := "2006-01-02"
-	manager := newManager(queueName, job, 1)
+	manager := defaultServer.newManager(queueName, job, 1)
 	worker := newWorker(manager)
 	message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
 
@@ -48,7 +49,7 @@ func MiddlewareStatsSpec(c gospec.Context) {
 			panic("AHHHH")
 		})
 
-		manager := newManager(queueName, job, 1)
+		manager := defaultServer.newManager(queueName, job, 1)
 		worker := newWorker(manager)
 
 		c.Specify("increments failed stats", func() {
@@ -70,5 +71,79 @@ func MiddlewareStatsSpec(c gospec.Context) {
 		})
 	})
 
+	c.Specify("discarded job", func() {
+		var job = (func(message *Msg) {
+			panic(Discard(errors.New("no longer relevant")))
+		})
+
+		manager := defaultServer.newManager(queueName, job, 1)
+		worker := newWorker(manager)
+
+		c.Specify("increments processed stats", func() {
+			conn := Config.Client
+
+			worker.process(message)
+
+			processed, _ := strconv.Atoi(conn.Get(ctx, "prod:stat:processed").Val())
+			failed, _ := strconv.Atoi(conn.Get(ctx, "prod:stat:failed").Val())
+
+			c.Expect(processed, Equals, 1)
+			c.Expect(failed, Equals, 0)
+		})
+	})
+
+	c.Specify("counts jobs per queue and class", func() {
+		conn := Config.Client
+		today := time.Now().UTC().Format(layout)
+
+		message, _ := NewMsg("{\"jid\":\"2\",\"class\":\"Add\",\"retry\":true}")
+		worker.process(message)
+
+		c.Expect(conn.HGet(ctx, "prod:stat:processed:queue", queueName).Val(), Equals, "1")
+		c.Expect(conn.HGet(ctx, "prod:stat:processed:queue:"+today, queueName).Val(), Equals, "1")
+		c.Expect(conn.HGet(ctx, "prod:stat:processed:class", "Add").Val(), Equals, "1")
+		c.Expect(conn.HGet(ctx, "prod:stat:processed:class:"+today, "Add").Val(), Equals, "1")
+
+		ttl, _ := conn.TTL(ctx, "prod:stat:processed:queue:"+today).Result()
+		c.Expect(ttl < 0, IsTrue)
+	})
+
+	c.Specify("expires daily stats", func() {
+		defaultServer.config.DailyStatsExpiration = 48 * time.Hour
+		today := time.Now().UTC().Format(layout)
+
+		worker.process(message)
+
+		ttl, _ := Config.Client.TTL(ctx, "prod:stat:processed:"+today).Result()
+		c.Expect(ttl > 47*time.Hour, IsTrue)
+
+		ttl, _ = Config.Client.TTL(ctx, "prod:stat:processed:queue:"+today).Result()
+		c.Expect(ttl > 47*time.Hour, IsTrue)
+
+		ttl, _ = Config.Client.TTL(ctx, "prod:stat:processed").Result()
+		c.Expect(ttl < 0, IsTrue)
+
+		defaultServer.config.DailyStatsExpiration = 0
+	})
+
+	c.Specify("reports stats per queue and class", func() {
+		job := func(message *Msg) {
+			if message.Args().MustArray()[0] == "fail" {
+				panic("AHHHH")
+			}
+		}
+		worker := newWorker(defaultServer.newManager(queueName, job, 1))
+
+		succeeded, _ := NewMsg("{\"jid\":\"1\",\"class\":\"Add\",\"args\":[\"ok\"]}")
+		failed, _ := NewMsg("{\"jid\":\"2\",\"class\":\"Add\",\"args\":[\"fail\"],\"retry\":true}")
+		worker.process(succeeded)
+		worker.process(failed)
+
+		stats := GetStats()
+
+		c.Expect(*stats.ByQueue[queueName], Equals, StatCounts{1, 1, 1, 1})
+		c.Expect(*stats.ByClass["Add"], Equals, StatCounts{1, 1, 1, 1})
+	})
+
 	Config.Namespace = was
 }
@@ -1,7 +1,10 @@
 package workers
 
 import (
+	"context"
 	"reflect"
+	"sync/atomic"
+	"time"
 
 	"github.com/bitly/go-simplejson"
 )
@@ -12,9 +15,35 @@ type data struct {
 
 type Msg struct {
 	*data
-	original string
+	original  string
+	ctx       context.Context
+	server    *Server
+	startedAt time.Time
+	// deferred is set once the message is stored to run again later, e.g.
+	// for a retry.
+	deferred bool
+	// dead is set once the message is moved to the dead set
+	dead bool
+	// interrupted is set when the job failed once the shutdown timeout
+	// cancelled its context, to run it again.
+	interrupted bool
+	// status is the last state tracked for the job in this process
+	status string
+	// settled is set once the job stored the outcome of failing for good
+	settled bool
+	// discarded is the error the job failed with when it was discarded
+	discarded error
+	// released is claimed, atomically, by whichever is done with the
+	// message first: its worker once the job returns, or the shutdown
+	// deadline pushing it back to its queue while still running.
+	released int32
 }
 
+const (
+	releasedByWorker int32 = iota + 1
+	releasedOnShutdown
+)
+
 type Args struct {
 	*data
 }
@@ -32,6 +61,47 @@ func (m *Msg) Args() *Args {
 	}
 }
 
+// Context returns the context of the job, which is cancelled when workers
+// give up waiting for it on shutdown.
+func (m *Msg) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// elapsed returns how long the job has been running, or zero before it runs
+func (m *Msg) elapsed() time.Duration {
+	if m.startedAt.IsZero() {
+		return 0
+	}
+	return time.Since(m.startedAt)
+}
+
+// release claims the message for its worker once the job returned. It
+// returns false when the shutdown deadline already pushed it back.
+func (m *Msg) release() bool {
+	return atomic.CompareAndSwapInt32(&m.released, 0, releasedByWorker)
+}
+
+// abandon claims the message of a job still running at the shutdown
+// deadline, to push it back to its queue. It returns false once its worker
+// is done with it.
+func (m *Msg) abandon() bool {
+	return atomic.CompareAndSwapInt32(&m.released, 0, releasedOnShutdown)
+}
+
+// abandoned reports whether the shutdown deadline pushed the message back
+func (m *Msg) abandoned() bool {
+	return atomic.LoadInt32(&m.released) == releasedOnShutdown
+}
+
+// expired reports whether the job wasn't run before its expires_at
+func (m *Msg) expired() bool {
+	expiresAt, err := m.Get("expires_at").Float64()
+	return err == nil && expiresAt > 0 && expiresAt < nowToSecondsWithNanoPrecision()
+}
+
 func (m *Msg) OriginalJson() string {
 	return m.original
 }
@@ -55,7 +125,7 @@ func NewMsg(content string) (*Msg, error) {
 	if d, err := newData(content); err != nil {
 		return nil, err
 	} else {
-		return &Msg{d, content}, nil
+		return &Msg{d, content, nil, nil, time.Time{}, false, false, false, "", false, nil, 0}, nil
 	}
 }
 
@@ -0,0 +1,103 @@
+package workers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Jobs enqueued with a partition key wait in a list per queue and key, in
+// enqueue order, while only the head of the list is in the queue. The next
+// job is pushed to the queue once the head is done for good: it succeeded,
+// was discarded, died or expired. A job scheduled for a retry stays at the
+// head, holding back the jobs after it.
+
+// pushPartitionScript appends a job to its partition, pushing it to the
+// queue when the partition was empty. The head of the partition coming back
+// from a retry is pushed to the queue right away.
+var pushPartitionScript = redis.NewScript(`
+if redis.call('LINDEX', KEYS[1], 0) == ARGV[1] then
+  return redis.call('LPUSH', KEYS[3], ARGV[2])
+end
+redis.call('RPUSH', KEYS[1], ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+if redis.call('LLEN', KEYS[1]) == 1 then
+  redis.call('LPUSH', KEYS[3], ARGV[2])
+end
+return 1
+`)
+
+// completePartitionScript removes a job from the head of its partition and
+// pushes the next job to the queue. It does nothing when the job isn't the
+// head, e.g. when it already completed.
+var completePartitionScript = redis.NewScript(`
+if redis.call('LINDEX', KEYS[1], 0) ~= ARGV[1] then
+  return 0
+end
+redis.call('LPOP', KEYS[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+local next = redis.call('LINDEX', KEYS[1], 0)
+if next then
+  local payload = redis.call('HGET', KEYS[2], next)
+  if payload then
+    redis.call('LPUSH', KEYS[3], payload)
+  end
+end
+return 1
+`)
+
+// partition returns the partition key of a message, or "" when its jobs
+// can run in any order.
+func (m *Msg) partition() string {
+	partition, _ := m.Get("partition").String()
+	return partition
+}
+
+func partitionKeys(config *WorkerConfig, queue, partition string) []string {
+	queue = strings.TrimPrefix(queue, config.Namespace)
+	key := config.Namespace + PARTITION_KEY + ":" + queue + ":" + partition
+
+	return []string{key, key + ":jobs", config.Namespace + "queue:" + queue}
+}
+
+// pushPartitioned queues pushing a job to its partition in pipe. The script
+// is sent whole, as a pipeline can't fall back to it when redis doesn't have
+// it cached.
+func pushPartitioned(ctx context.Context, pipe redis.Pipeliner, config *WorkerConfig, queue, partition, jid, payload string) {
+	pushPartitionScript.Eval(ctx, pipe, partitionKeys(config, queue, partition), jid, payload)
+}
+
+// Completing a partition is attempted completePartitionAttempts times,
+// completePartitionBackoff apart, before the partition is left stalled.
+const (
+	completePartitionAttempts = 3
+	completePartitionBackoff  = 100 * time.Millisecond
+)
+
+// completePartition lets the next job of the partition of a message run. The
+// script does nothing for a job which isn't the head anymore, so it is safe
+// to retry.
+func completePartition(ctx context.Context, config *WorkerConfig, queue string, message *Msg) error {
+	partition := message.partition()
+	if partition == "" {
+		return nil
+	}
+
+	var err error
+	for attempt := 1; attempt <= completePartitionAttempts; attempt++ {
+		err = completePartitionScript.Run(ctx, config.Client, partitionKeys(config, queue, partition), message.Jid()).Err()
+		if err == nil {
+			return nil
+		}
+
+		Logger.Errorln("failed to complete partition", partition, "of", message.Jid(), "attempt", attempt, ":", err)
+		if attempt < completePartitionAttempts {
+			time.Sleep(time.Duration(attempt) * completePartitionBackoff)
+		}
+	}
+
+	Logger.Errorln("partition", partition, "of queue", queue, "is stalled until", message.Jid(), "completes it")
+	return err
+}
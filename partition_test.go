@@ -0,0 +1,145 @@
+package workers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func PartitionSpec(c gospec.Context) {
+	const queueName = "queue-partition"
+
+	ctx := context.Background()
+	conn := Config.Client
+
+	enqueue := func(partition string, arg int) string {
+		jid, _ := EnqueueWithOptions(queueName, "Add", []int{arg}, EnqueueOptions{Partition: partition, Retry: true})
+		return jid
+	}
+
+	succeed := func(message *Msg) {}
+
+	fetch := func() *Msg {
+		payload, _ := conn.RPop(ctx, "queue:"+queueName).Result()
+		message, _ := NewMsg(payload)
+		return message
+	}
+
+	c.Specify("only queues the first job of a partition", func() {
+		first := enqueue("user-1", 1)
+		enqueue("user-1", 2)
+		other := enqueue("user-2", 3)
+
+		count, _ := conn.LLen(ctx, "queue:"+queueName).Result()
+		c.Expect(count, Equals, int64(2))
+		c.Expect(fetch().Jid(), Equals, first)
+		c.Expect(fetch().Jid(), Equals, other)
+	})
+
+	c.Specify("queues the next job once the first is done", func() {
+		enqueue("user-1", 1)
+		second := enqueue("user-1", 2)
+
+		worker := newWorker(defaultServer.newManager(queueName, succeed, 1))
+		c.Expect(worker.process(fetch()), IsTrue)
+
+		c.Expect(fetch().Jid(), Equals, second)
+	})
+
+	c.Specify("holds back the next jobs while the first is retried", func() {
+		first := enqueue("user-1", 1)
+		second := enqueue("user-1", 2)
+
+		worker := newWorker(defaultServer.newManager(queueName, func(message *Msg) {
+			panic("AHHHH")
+		}, 1))
+		worker.process(fetch())
+
+		count, _ := conn.LLen(ctx, "queue:"+queueName).Result()
+		c.Expect(count, Equals, int64(0))
+
+		entries, _ := defaultServer.RetrySet().List(ctx, 0, -1)
+		c.Expect(len(entries), Equals, 1)
+		defaultServer.RetrySet().Retry(ctx, entries[0])
+
+		retried := fetch()
+		c.Expect(retried.Jid(), Equals, first)
+
+		worker = newWorker(defaultServer.newManager(queueName, succeed, 1))
+		worker.process(retried)
+
+		c.Expect(fetch().Jid(), Equals, second)
+	})
+
+	c.Specify("queues the next job once the retried first is deleted", func() {
+		enqueue("user-1", 1)
+		second := enqueue("user-1", 2)
+
+		worker := newWorker(defaultServer.newManager(queueName, func(message *Msg) {
+			panic("AHHHH")
+		}, 1))
+		worker.process(fetch())
+
+		entries, _ := defaultServer.RetrySet().List(ctx, 0, -1)
+		c.Expect(len(entries), Equals, 1)
+		c.Expect(defaultServer.RetrySet().Delete(ctx, entries[0]), IsNil)
+
+		c.Expect(fetch().Jid(), Equals, second)
+	})
+
+	c.Specify("queues the next jobs once the retry set is cleared", func() {
+		enqueue("user-1", 1)
+		second := enqueue("user-1", 2)
+		enqueue("user-2", 3)
+		other := enqueue("user-2", 4)
+
+		worker := newWorker(defaultServer.newManager(queueName, func(message *Msg) {
+			panic("AHHHH")
+		}, 1))
+		worker.process(fetch())
+		worker.process(fetch())
+
+		c.Expect(defaultServer.RetrySet().Clear(ctx), IsNil)
+
+		c.Expect(fetch().Jid(), Equals, second)
+		c.Expect(fetch().Jid(), Equals, other)
+	})
+
+	c.Specify("runs jobs of a partition in order and partitions in parallel", func() {
+		var mutex sync.Mutex
+		order := make(map[string][]int)
+		done := make(chan bool)
+
+		Process(queueName, func(message *Msg) {
+			arg, _ := message.Args().GetIndex(0).Int()
+
+			mutex.Lock()
+			order[message.partition()] = append(order[message.partition()], arg)
+			mutex.Unlock()
+
+			done <- true
+		}, 4)
+
+		for i := 0; i < 4; i++ {
+			enqueue("user-1", i)
+			enqueue("user-2", i)
+		}
+
+		Start()
+		for i := 0; i < 8; i++ {
+			<-done
+		}
+		Quit()
+
+		expected := []int{0, 1, 2, 3}
+		c.Expect(order["user-1"], ContainsExactly, expected)
+		c.Expect(order["user-2"], ContainsExactly, expected)
+
+		for i := range expected {
+			c.Expect(order["user-1"][i], Equals, expected[i])
+			c.Expect(order["user-2"][i], Equals, expected[i])
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+)
+
+// PauseQueue stops every process from fetching messages from a queue, while
+// their in-flight jobs finish. Other processes pick it up within
+// Config.PoolInterval seconds.
+func PauseQueue(queue string) error {
+	return defaultServer.PauseQueue(queue)
+}
+
+// ResumeQueue resumes a queue paused with PauseQueue in every process
+func ResumeQueue(queue string) error {
+	return defaultServer.ResumeQueue(queue)
+}
+
+// PauseQueueLocally stops this process from fetching messages from a queue,
+// while its in-flight jobs finish.
+func PauseQueueLocally(queue string) error {
+	return defaultServer.PauseQueueLocally(queue)
+}
+
+// ResumeQueueLocally resumes a queue paused with PauseQueueLocally. It keeps
+// being paused while paused fleet-wide.
+func ResumeQueueLocally(queue string) error {
+	return defaultServer.ResumeQueueLocally(queue)
+}
+
+// PausedQueues returns the queues paused fleet-wide
+func PausedQueues() ([]string, error) {
+	return defaultServer.PausedQueues()
+}
+
+// PauseQueue stops every process of the server's namespace from fetching
+// messages from a queue.
+func (s *Server) PauseQueue(queue string) error {
+	ctx := context.Background()
+
+	if err := s.config.Client.SAdd(ctx, s.config.Namespace+PAUSED_KEY, queue).Err(); err != nil {
+		return err
+	}
+
+	s.setPaused(queue, pausedByFleet, true)
+
+	return nil
+}
+
+// ResumeQueue resumes a queue paused with PauseQueue in every process
+func (s *Server) ResumeQueue(queue string) error {
+	ctx := context.Background()
+
+	if err := s.config.Client.SRem(ctx, s.config.Namespace+PAUSED_KEY, queue).Err(); err != nil {
+		return err
+	}
+
+	s.setPaused(queue, pausedByFleet, false)
+
+	return nil
+}
+
+// PauseQueueLocally stops the server from fetching messages from a queue
+func (s *Server) PauseQueueLocally(queue string) error {
+	if !s.setPaused(queue, pausedLocally, true) {
+		return fmt.Errorf("unknown queue %s", queue)
+	}
+	return nil
+}
+
+// ResumeQueueLocally resumes a queue paused with PauseQueueLocally
+func (s *Server) ResumeQueueLocally(queue string) error {
+	if !s.setPaused(queue, pausedLocally, false) {
+		return fmt.Errorf("unknown queue %s", queue)
+	}
+	return nil
+}
+
+// PausedQueues returns the queues paused fleet-wide
+func (s *Server) PausedQueues() ([]string, error) {
+	return s.config.Client.SMembers(context.Background(), s.config.Namespace+PAUSED_KEY).Result()
+}
+
+func (s *Server) setPaused(queue string, source pauseSource, paused bool) bool {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	m, ok := s.managers[queue]
+	if ok {
+		m.setPaused(source, paused)
+	}
+
+	return ok
+}
@@ -0,0 +1,100 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func PauseSpec(c gospec.Context) {
+	ctx := context.Background()
+	const queueName = "queue-pause"
+
+	processed := make(chan *Args)
+	job := (func(message *Msg) {
+		processed <- message.Args()
+	})
+
+	c.Specify("paused manager", func() {
+		conn := Config.Client
+		message, _ := NewMsg("{\"jid\":\"1\",\"args\":[\"foo\"]}")
+
+		c.Specify("doesn't fetch messages until resumed", func() {
+			manager := defaultServer.newManager(queueName, job, 2)
+			manager.setPaused(pausedLocally, true)
+			manager.start()
+
+			conn.LPush(ctx, "queue:"+queueName, message.ToJson())
+			time.Sleep(100 * time.Millisecond)
+
+			count, _ := conn.LLen(ctx, "queue:"+queueName).Result()
+			c.Expect(count, Equals, int64(1))
+
+			manager.setPaused(pausedLocally, false)
+			c.Expect(<-processed, Equals, message.Args())
+
+			manager.quit()
+		})
+
+		c.Specify("stays paused while any source pauses it", func() {
+			manager := defaultServer.newManager(queueName, job, 1)
+
+			manager.setPaused(pausedLocally, true)
+			manager.setPaused(pausedByFleet, true)
+			manager.setPaused(pausedLocally, false)
+			c.Expect(manager.isPaused(), IsTrue)
+
+			manager.setPaused(pausedByFleet, false)
+			c.Expect(manager.isPaused(), IsFalse)
+		})
+	})
+
+	c.Specify("PauseQueue", func() {
+		conn := Config.Client
+		Process(queueName, job, 1)
+
+		c.Specify("pauses the queue in every process", func() {
+			err := PauseQueue(queueName)
+			c.Expect(err, IsNil)
+
+			paused, _ := conn.SIsMember(ctx, PAUSED_KEY, queueName).Result()
+			c.Expect(paused, IsTrue)
+			c.Expect(defaultServer.managers[queueName].isPaused(), IsTrue)
+			c.Expect(GetStats().Paused, ContainsExactly, Values(queueName))
+
+			ResumeQueue(queueName)
+
+			paused, _ = conn.SIsMember(ctx, PAUSED_KEY, queueName).Result()
+			c.Expect(paused, IsFalse)
+			c.Expect(defaultServer.managers[queueName].isPaused(), IsFalse)
+		})
+
+		c.Specify("is applied from redis by other processes", func() {
+			conn.SAdd(ctx, PAUSED_KEY, queueName)
+
+			newFleetPoller(defaultServer).poll(ctx)
+			c.Expect(defaultServer.managers[queueName].isPaused(), IsTrue)
+
+			conn.SRem(ctx, PAUSED_KEY, queueName)
+
+			newFleetPoller(defaultServer).poll(ctx)
+			c.Expect(defaultServer.managers[queueName].isPaused(), IsFalse)
+		})
+
+		c.Specify("can be done locally", func() {
+			err := PauseQueueLocally(queueName)
+			c.Expect(err, IsNil)
+			c.Expect(defaultServer.managers[queueName].isPaused(), IsTrue)
+
+			ResumeQueueLocally(queueName)
+			c.Expect(defaultServer.managers[queueName].isPaused(), IsFalse)
+
+			err = PauseQueueLocally("unknown")
+			c.Expect(err, Not(IsNil))
+		})
+
+		ResetManagers()
+	})
+}
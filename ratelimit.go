@@ -0,0 +1,515 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimiter bounds how often jobs run across every process sharing a
+// redis. Limiters are selected per queue or per class with SetQueueRateLimit
+// and SetClassRateLimit, and keep their state in redis under key.
+type RateLimiter interface {
+	// Acquire takes a slot for the job jid. It returns zero when the job
+	// can run, or how long to wait before a slot may be free.
+	Acquire(ctx context.Context, conn redis.UniversalClient, key, jid string) (time.Duration, error)
+	// Release frees the slot of jid once its job is done
+	Release(ctx context.Context, conn redis.UniversalClient, key, jid string) error
+}
+
+// TokenBucket lets Rate jobs run per Interval, with bursts of up to Burst
+// jobs. Burst defaults to Rate and Interval to a second.
+type TokenBucket struct {
+	Rate     int
+	Interval time.Duration
+	Burst    int
+}
+
+// SlidingWindow lets at most Limit jobs start within any Window
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+}
+
+// ConcurrentLimit lets at most Limit jobs run at once. Slots are leased for
+// Lease, 30 seconds by default, and renewed while their job runs, so the
+// slots of processes which died are freed once their lease expires.
+//
+// When Key is set, jobs are limited separately per key derived from their
+// message, e.g. one limit per customer found in the args.
+type ConcurrentLimit struct {
+	Limit int
+	Lease time.Duration
+	Key   func(message *Msg) string
+}
+
+// Jobs over a limit wait up to rateLimitWait for a slot before being
+// rescheduled, polling concurrent limits every concurrentLimitPoll.
+const (
+	rateLimitWait       = time.Second
+	concurrentLimitPoll = 100 * time.Millisecond
+	defaultLease        = 30 * time.Second
+)
+
+// SetQueueRateLimit limits the jobs of a queue across every process, or
+// removes its limit when limiter is nil. It fails for limiters which would
+// never let a job run.
+func SetQueueRateLimit(queue string, limiter RateLimiter) error {
+	return defaultServer.SetQueueRateLimit(queue, limiter)
+}
+
+// SetClassRateLimit limits the jobs of a class across every process and
+// queue, or removes its limit when limiter is nil. It fails for limiters
+// which would never let a job run.
+func SetClassRateLimit(class string, limiter RateLimiter) error {
+	return defaultServer.SetClassRateLimit(class, limiter)
+}
+
+func (s *Server) SetQueueRateLimit(queue string, limiter RateLimiter) error {
+	return s.setRateLimit(s.queueLimits, queue, limiter)
+}
+
+func (s *Server) SetClassRateLimit(class string, limiter RateLimiter) error {
+	return s.setRateLimit(s.classLimits, class, limiter)
+}
+
+// Rate limits are guarded by limitsM, as workers read them while Quit
+// holds access.
+func (s *Server) setRateLimit(limits map[string]RateLimiter, name string, limiter RateLimiter) error {
+	if v, ok := limiter.(validated); ok {
+		if err := v.validate(); err != nil {
+			return err
+		}
+	}
+
+	s.limitsM.Lock()
+	defer s.limitsM.Unlock()
+
+	if limiter == nil {
+		delete(limits, name)
+	} else {
+		limits[name] = limiter
+	}
+	return nil
+}
+
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(state[1]) or burst
+local at = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - at) * rate)
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = (1 - tokens) / rate
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil(burst / rate) + 1)
+return tostring(wait)
+`)
+
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) < limit then
+  redis.call('ZADD', KEYS[1], now, ARGV[4])
+  redis.call('EXPIRE', KEYS[1], math.ceil(window))
+  return '0'
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return tostring(tonumber(oldest[2]) + window - now)
+`)
+
+var concurrentLimitScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local lease = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZSCORE', KEYS[1], ARGV[4]) or redis.call('ZCARD', KEYS[1]) < limit then
+  redis.call('ZADD', KEYS[1], now + lease, ARGV[4])
+  redis.call('EXPIRE', KEYS[1], math.ceil(lease))
+  return '0'
+end
+local first = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return tostring(tonumber(first[2]) - now)
+`)
+
+var tokenBucketRefundScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+if tokens then
+  redis.call('HSET', KEYS[1], 'tokens', tostring(math.min(burst, tokens + 1)))
+end
+return 1
+`)
+
+var slidingWindowRefundScript = redis.NewScript(`
+local prefix = ARGV[1] .. ':'
+for _, member in ipairs(redis.call('ZREVRANGE', KEYS[1], 0, -1)) do
+  if string.sub(member, 1, string.len(prefix)) == prefix then
+    redis.call('ZREM', KEYS[1], member)
+    return 1
+  end
+end
+return 0
+`)
+
+func (l TokenBucket) Acquire(ctx context.Context, conn redis.UniversalClient, key, jid string) (time.Duration, error) {
+	interval := l.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	rate := float64(l.Rate) / interval.Seconds()
+
+	return runLimitScript(ctx, tokenBucketScript, conn, key, rate, l.burst(), nowToSecondsWithNanoPrecision())
+}
+
+func (l TokenBucket) Release(ctx context.Context, conn redis.UniversalClient, key, jid string) error {
+	return nil
+}
+
+// refund gives back the token taken by jid
+func (l TokenBucket) refund(ctx context.Context, conn redis.UniversalClient, key, jid string) error {
+	return tokenBucketRefundScript.Run(ctx, conn, []string{key}, l.burst()).Err()
+}
+
+func (l TokenBucket) burst() int {
+	if l.Burst <= 0 {
+		return l.Rate
+	}
+	return l.Burst
+}
+
+func (l TokenBucket) validate() error {
+	if l.Rate <= 0 {
+		return fmt.Errorf("token bucket rate must be positive, got %d", l.Rate)
+	}
+	return nil
+}
+
+func (l SlidingWindow) Acquire(ctx context.Context, conn redis.UniversalClient, key, jid string) (time.Duration, error) {
+	now := nowToSecondsWithNanoPrecision()
+	member := fmt.Sprint(jid, ":", now)
+
+	return runLimitScript(ctx, slidingWindowScript, conn, key, l.Limit, l.Window.Seconds(), now, member)
+}
+
+func (l SlidingWindow) Release(ctx context.Context, conn redis.UniversalClient, key, jid string) error {
+	return nil
+}
+
+// refund removes the latest start of jid from the window
+func (l SlidingWindow) refund(ctx context.Context, conn redis.UniversalClient, key, jid string) error {
+	return slidingWindowRefundScript.Run(ctx, conn, []string{key}, jid).Err()
+}
+
+func (l SlidingWindow) validate() error {
+	if l.Limit <= 0 {
+		return fmt.Errorf("sliding window limit must be positive, got %d", l.Limit)
+	}
+	if l.Window <= 0 {
+		return fmt.Errorf("sliding window must be positive, got %v", l.Window)
+	}
+	return nil
+}
+
+func (l ConcurrentLimit) Acquire(ctx context.Context, conn redis.UniversalClient, key, jid string) (time.Duration, error) {
+	wait, err := runLimitScript(ctx, concurrentLimitScript, conn, key, l.Limit, l.lease().Seconds(), nowToSecondsWithNanoPrecision(), jid)
+
+	// Slots are usually released well before their lease expires
+	if wait > concurrentLimitPoll {
+		wait = concurrentLimitPoll
+	}
+
+	return wait, err
+}
+
+func (l ConcurrentLimit) Release(ctx context.Context, conn redis.UniversalClient, key, jid string) error {
+	return conn.ZRem(ctx, key, jid).Err()
+}
+
+func (l ConcurrentLimit) validate() error {
+	if l.Limit <= 0 {
+		return fmt.Errorf("concurrent limit must be positive, got %d", l.Limit)
+	}
+	return nil
+}
+
+func (l ConcurrentLimit) lease() time.Duration {
+	if l.Lease <= 0 {
+		return defaultLease
+	}
+	return l.Lease
+}
+
+// partition returns the key of a message, falling back to the limit shared
+// by every key when Key panics, as the job isn't running yet to fail.
+func (l ConcurrentLimit) partition(message *Msg) (partition string) {
+	if l.Key == nil {
+		return ""
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			Logger.Errorln("rate limit key of", message.Jid(), "panicked:", e)
+			partition = ""
+		}
+	}()
+
+	return l.Key(message)
+}
+
+// partitioned is implemented by limiters keeping a separate limit per key
+// derived from messages.
+type partitioned interface {
+	partition(message *Msg) string
+}
+
+// validated is implemented by limiters whose settings can be checked when
+// they are set.
+type validated interface {
+	validate() error
+}
+
+// refundable is implemented by limiters whose Release doesn't give back what
+// Acquire took, e.g. the token of a token bucket, so that a job refused by
+// another of its limiters doesn't use up their capacity.
+type refundable interface {
+	refund(ctx context.Context, conn redis.UniversalClient, key, jid string) error
+}
+
+// leased is implemented by limiters whose slots expire unless the job
+// holding them acquires them again before the lease ends.
+type leased interface {
+	lease() time.Duration
+}
+
+func runLimitScript(ctx context.Context, script *redis.Script, conn redis.UniversalClient, key string, args ...interface{}) (time.Duration, error) {
+	result, err := script.Run(ctx, conn, []string{key}, args...).Text()
+	if err != nil {
+		return 0, err
+	}
+
+	wait, err := strconv.ParseFloat(result, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return seconds(math.Max(wait, 0)), nil
+}
+
+type rateLimit struct {
+	key     string
+	limiter RateLimiter
+}
+
+// rateLimitsFor returns the limiters applying to a message: the one of its
+// queue, then the one of its class.
+func (s *Server) rateLimitsFor(queue string, message *Msg) []rateLimit {
+	s.limitsM.RLock()
+	defer s.limitsM.RUnlock()
+
+	var limits []rateLimit
+	if limiter, ok := s.queueLimits[queue]; ok {
+		limits = append(limits, rateLimit{limitKey(s.config.Namespace+RATE_LIMIT_KEY+":queue:"+queue, limiter, message), limiter})
+	}
+
+	class, _ := message.Get("class").String()
+	if limiter, ok := s.classLimits[class]; ok {
+		limits = append(limits, rateLimit{limitKey(s.config.Namespace+RATE_LIMIT_KEY+":class:"+class, limiter, message), limiter})
+	}
+
+	return limits
+}
+
+func limitKey(key string, limiter RateLimiter, message *Msg) string {
+	if p, ok := limiter.(partitioned); ok {
+		if partition := p.partition(message); partition != "" {
+			return key + ":" + partition
+		}
+	}
+	return key
+}
+
+// limit takes a slot from every limiter applying to a message, waiting
+// briefly when one is over its limit. It returns false after rescheduling
+// the message when no slot frees up in time, without counting a retry.
+// Otherwise, release must be called once the job is done.
+//
+// Limiters failing to reach redis, or a failed reschedule, let the job run.
+func (s *Server) limit(queue string, message *Msg) (release func(), ok bool) {
+	limits := s.rateLimitsFor(queue, message)
+	if len(limits) == 0 {
+		return func() {}, true
+	}
+
+	ctx := message.Context()
+	deadline := time.Now().Add(rateLimitWait)
+
+	for {
+		acquired, wait := s.acquire(ctx, limits, message)
+		if wait == 0 {
+			stop := s.renewLeases(acquired, message)
+			return func() {
+				stop()
+				s.release(acquired, message)
+			}, true
+		}
+
+		if time.Now().Add(wait).After(deadline) {
+			if err := s.reschedule(ctx, queue, message, wait); err != nil {
+				Logger.Errorln("failed to reschedule rate limited job", message.Jid(), ":", err)
+				return func() {}, true
+			}
+			return nil, false
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			// Interrupted by the shutdown timeout before it ran
+			message.interrupted = true
+			return nil, false
+		}
+	}
+}
+
+// acquire takes a slot from every limiter, and returns the ones which
+// granted it. Limiters failing to reach redis are skipped. When one is over
+// its limit, it returns its wait after giving back the slots taken from the
+// others.
+func (s *Server) acquire(ctx context.Context, limits []rateLimit, message *Msg) ([]rateLimit, time.Duration) {
+	conn := s.config.Client
+	jid := message.Jid()
+
+	var acquired []rateLimit
+	for _, limit := range limits {
+		wait, err := limit.limiter.Acquire(ctx, conn, limit.key, jid)
+		if err != nil {
+			Logger.Errorln("failed to check rate limit", limit.key, "of", jid, ":", err)
+			continue
+		}
+
+		if wait > 0 {
+			s.refund(acquired, message)
+			return nil, wait
+		}
+
+		acquired = append(acquired, limit)
+	}
+
+	return acquired, 0
+}
+
+// release gives back the slots of a job once it is done
+func (s *Server) release(limits []rateLimit, message *Msg) {
+	for _, limit := range limits {
+		if err := limit.limiter.Release(context.Background(), s.config.Client, limit.key, message.Jid()); err != nil {
+			Logger.Errorln("failed to release rate limit", limit.key, "of", message.Jid(), ":", err)
+		}
+	}
+}
+
+// refund gives back the slots of a job which didn't run, as if it never
+// took them.
+func (s *Server) refund(limits []rateLimit, message *Msg) {
+	for _, limit := range limits {
+		r, ok := limit.limiter.(refundable)
+		if !ok {
+			s.release([]rateLimit{limit}, message)
+			continue
+		}
+		if err := r.refund(context.Background(), s.config.Client, limit.key, message.Jid()); err != nil {
+			Logger.Errorln("failed to refund rate limit", limit.key, "of", message.Jid(), ":", err)
+		}
+	}
+}
+
+// renewLeases acquires leased slots again while their job runs, until the
+// returned function is called.
+func (s *Server) renewLeases(limits []rateLimit, message *Msg) func() {
+	var renewed []rateLimit
+	interval := time.Duration(math.MaxInt64)
+
+	for _, limit := range limits {
+		if l, ok := limit.limiter.(leased); ok {
+			renewed = append(renewed, limit)
+			if l.lease()/3 < interval {
+				interval = l.lease() / 3
+			}
+		}
+	}
+
+	if len(renewed) == 0 {
+		return func() {}
+	}
+
+	done := make(chan bool)
+	exited := make(chan bool)
+	go (func() {
+		defer close(exited)
+
+		conn := s.config.Client
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				for _, limit := range renewed {
+					wait, err := limit.limiter.Acquire(context.Background(), conn, limit.key, message.Jid())
+					if err != nil {
+						Logger.Errorln("failed to renew rate limit", limit.key, "of", message.Jid(), ":", err)
+					} else if wait > 0 {
+						Logger.Warnln("lost rate limit", limit.key, "of", message.Jid())
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	})()
+
+	// Wait for a renewal in flight, which would take the slot again after
+	// its release.
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// reschedule moves a message over its limit to the schedule set, to run
+// once its limiter lets it.
+func (s *Server) reschedule(ctx context.Context, queue string, message *Msg, wait time.Duration) error {
+	if q, _ := message.Get("queue").String(); q == "" {
+		message.Set("queue", queue)
+	}
+
+	zItem := redis.Z{
+		Score:  nowToSecondsWithNanoPrecision() + durationToSecondsWithNanoPrecision(wait),
+		Member: message.ToJson(),
+	}
+
+	pipe := s.config.Client.TxPipeline()
+	pipe.ZAdd(ctx, s.config.Namespace+SCHEDULED_JOBS_KEY, zItem)
+	if message.tracked() {
+		queueStatus(ctx, pipe, s.config, message, STATUS_SCHEDULED)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	message.deferred = true
+	return nil
+}
@@ -0,0 +1,299 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+	"github.com/redis/go-redis/v9"
+)
+
+// unreachableLimiter fails to reach redis, counting the slots given back
+type unreachableLimiter struct {
+	released *int
+}
+
+func (l unreachableLimiter) Acquire(ctx context.Context, conn redis.UniversalClient, key, jid string) (time.Duration, error) {
+	return 0, errors.New("unreachable")
+}
+
+func (l unreachableLimiter) Release(ctx context.Context, conn redis.UniversalClient, key, jid string) error {
+	*l.released++
+	return nil
+}
+
+func RateLimitSpec(c gospec.Context) {
+	const queueName = "queue-ratelimit"
+
+	ctx := context.Background()
+	conn := Config.Client
+	key := "limit:test"
+
+	c.Specify("TokenBucket", func() {
+		limiter := TokenBucket{Rate: 2, Interval: time.Second}
+
+		c.Specify("lets Burst jobs run at once", func() {
+			wait, _ := limiter.Acquire(ctx, conn, key, "1")
+			c.Expect(wait, Equals, time.Duration(0))
+			wait, _ = limiter.Acquire(ctx, conn, key, "2")
+			c.Expect(wait, Equals, time.Duration(0))
+		})
+
+		c.Specify("waits for the next token once empty", func() {
+			limiter.Acquire(ctx, conn, key, "1")
+			limiter.Acquire(ctx, conn, key, "2")
+
+			wait, err := limiter.Acquire(ctx, conn, key, "3")
+			c.Expect(err, IsNil)
+			c.Expect(wait > 0, IsTrue)
+			c.Expect(wait <= 500*time.Millisecond, IsTrue)
+		})
+
+		c.Specify("gets a refunded token back", func() {
+			limiter.Acquire(ctx, conn, key, "1")
+			limiter.Acquire(ctx, conn, key, "2")
+			limiter.refund(ctx, conn, key, "2")
+
+			wait, _ := limiter.Acquire(ctx, conn, key, "3")
+			c.Expect(wait, Equals, time.Duration(0))
+		})
+	})
+
+	c.Specify("SlidingWindow", func() {
+		limiter := SlidingWindow{Limit: 2, Window: time.Minute}
+
+		c.Specify("waits for the oldest job to leave the window", func() {
+			limiter.Acquire(ctx, conn, key, "1")
+			limiter.Acquire(ctx, conn, key, "2")
+
+			wait, err := limiter.Acquire(ctx, conn, key, "3")
+			c.Expect(err, IsNil)
+			c.Expect(wait > 59*time.Second, IsTrue)
+			c.Expect(wait <= time.Minute, IsTrue)
+		})
+
+		c.Specify("removes a refunded job from the window", func() {
+			limiter.Acquire(ctx, conn, key, "1")
+			limiter.Acquire(ctx, conn, key, "2")
+			limiter.refund(ctx, conn, key, "2")
+
+			wait, _ := limiter.Acquire(ctx, conn, key, "3")
+			c.Expect(wait, Equals, time.Duration(0))
+		})
+	})
+
+	c.Specify("ConcurrentLimit", func() {
+		limiter := ConcurrentLimit{Limit: 1}
+
+		c.Specify("waits until a slot is released", func() {
+			limiter.Acquire(ctx, conn, key, "1")
+
+			wait, _ := limiter.Acquire(ctx, conn, key, "2")
+			c.Expect(wait > 0, IsTrue)
+
+			limiter.Release(ctx, conn, key, "1")
+
+			wait, _ = limiter.Acquire(ctx, conn, key, "2")
+			c.Expect(wait, Equals, time.Duration(0))
+		})
+
+		c.Specify("lets the holder of a slot acquire it again", func() {
+			limiter.Acquire(ctx, conn, key, "1")
+
+			wait, _ := limiter.Acquire(ctx, conn, key, "1")
+			c.Expect(wait, Equals, time.Duration(0))
+		})
+
+		c.Specify("frees slots whose lease expired", func() {
+			limiter := ConcurrentLimit{Limit: 1, Lease: time.Millisecond}
+			limiter.Acquire(ctx, conn, key, "1")
+			time.Sleep(5 * time.Millisecond)
+
+			wait, _ := limiter.Acquire(ctx, conn, key, "2")
+			c.Expect(wait, Equals, time.Duration(0))
+		})
+	})
+
+	c.Specify("rejects limiters which never let jobs run", func() {
+		c.Expect(SetQueueRateLimit(queueName, TokenBucket{Interval: time.Second}), Not(IsNil))
+		c.Expect(SetQueueRateLimit(queueName, SlidingWindow{Limit: 1}), Not(IsNil))
+		c.Expect(SetClassRateLimit("Limited", SlidingWindow{Window: time.Minute}), Not(IsNil))
+		c.Expect(SetClassRateLimit("Limited", ConcurrentLimit{}), Not(IsNil))
+		c.Expect(len(defaultServer.queueLimits), Equals, 0)
+		c.Expect(len(defaultServer.classLimits), Equals, 0)
+	})
+
+	c.Specify("process", func() {
+		ran := 0
+		manager := defaultServer.newManager(queueName, func(message *Msg) {
+			ran++
+		}, 1)
+		worker := newWorker(manager)
+
+		c.Specify("reschedules jobs over the limit of their queue", func() {
+			SetQueueRateLimit(queueName, SlidingWindow{Limit: 1, Window: time.Hour})
+
+			first, _ := NewMsg("{\"jid\":\"1\",\"args\":[]}")
+			second, _ := NewMsg("{\"jid\":\"2\",\"args\":[]}")
+
+			c.Expect(worker.process(first), IsTrue)
+			c.Expect(worker.process(second), IsTrue)
+			c.Expect(ran, Equals, 1)
+
+			scheduled, _ := conn.ZRangeWithScores(ctx, SCHEDULED_JOBS_KEY, 0, -1).Result()
+			c.Expect(len(scheduled), Equals, 1)
+
+			message, _ := NewMsg(scheduled[0].Member.(string))
+			c.Expect(message.Jid(), Equals, "2")
+			c.Expect(message.Get("queue").MustString(), Equals, queueName)
+			c.Expect(message.Get("retry_count").Interface(), IsNil)
+			c.Expect(scheduled[0].Score > nowToSecondsWithNanoPrecision()+3500, IsTrue)
+
+			SetQueueRateLimit(queueName, nil)
+		})
+
+		c.Specify("waits briefly for a slot of the class of jobs", func() {
+			SetClassRateLimit("Limited", TokenBucket{Rate: 10, Interval: time.Second, Burst: 1})
+
+			for i := 0; i < 3; i++ {
+				message, _ := NewMsg("{\"jid\":\"1\",\"class\":\"Limited\",\"args\":[]}")
+				c.Expect(worker.process(message), IsTrue)
+			}
+			c.Expect(ran, Equals, 3)
+
+			count, _ := conn.ZCard(ctx, SCHEDULED_JOBS_KEY).Result()
+			c.Expect(count, Equals, int64(0))
+
+			SetClassRateLimit("Limited", nil)
+		})
+
+		c.Specify("interrupts jobs waiting for a slot on shutdown", func() {
+			SetClassRateLimit("Limited", TokenBucket{Rate: 10, Interval: time.Second, Burst: 1})
+
+			first, _ := NewMsg("{\"jid\":\"1\",\"class\":\"Limited\",\"args\":[]}")
+			worker.process(first)
+
+			// Cancelled while waiting for the next token
+			cancelled, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			second, _ := NewMsg("{\"jid\":\"2\",\"class\":\"Limited\",\"args\":[]}")
+			second.ctx = cancelled
+
+			c.Expect(worker.process(second), IsTrue)
+			c.Expect(second.interrupted, IsTrue)
+			c.Expect(ran, Equals, 1)
+
+			SetClassRateLimit("Limited", nil)
+		})
+
+		c.Specify("gives back the slots taken when another limiter is over its limit", func() {
+			limiter := TokenBucket{Rate: 1, Interval: time.Hour}
+			SetQueueRateLimit(queueName, limiter)
+			SetClassRateLimit("Limited", ConcurrentLimit{Limit: 1})
+			ConcurrentLimit{Limit: 1}.Acquire(ctx, conn, RATE_LIMIT_KEY+":class:Limited", "other")
+
+			message, _ := NewMsg("{\"jid\":\"1\",\"class\":\"Limited\",\"args\":[]}")
+			c.Expect(worker.process(message), IsTrue)
+			c.Expect(ran, Equals, 0)
+
+			wait, _ := limiter.Acquire(ctx, conn, RATE_LIMIT_KEY+":queue:"+queueName, "2")
+			c.Expect(wait, Equals, time.Duration(0))
+
+			SetQueueRateLimit(queueName, nil)
+			SetClassRateLimit("Limited", nil)
+		})
+
+		c.Specify("only gives back the slots of limiters which granted them", func() {
+			released := 0
+			SetQueueRateLimit(queueName, unreachableLimiter{&released})
+			SetClassRateLimit("Limited", ConcurrentLimit{Limit: 1})
+
+			message, _ := NewMsg("{\"jid\":\"1\",\"args\":[]}")
+			worker.process(message)
+			c.Expect(ran, Equals, 1)
+
+			ConcurrentLimit{Limit: 1}.Acquire(ctx, conn, RATE_LIMIT_KEY+":class:Limited", "other")
+			limited, _ := NewMsg("{\"jid\":\"2\",\"class\":\"Limited\",\"args\":[]}")
+			worker.process(limited)
+			c.Expect(ran, Equals, 1)
+
+			c.Expect(released, Equals, 0)
+
+			SetQueueRateLimit(queueName, nil)
+			SetClassRateLimit("Limited", nil)
+		})
+
+		c.Specify("releases concurrent slots once jobs are done", func() {
+			SetQueueRateLimit(queueName, ConcurrentLimit{Limit: 1})
+
+			message, _ := NewMsg("{\"jid\":\"1\",\"args\":[]}")
+			c.Expect(worker.process(message), IsTrue)
+
+			count, _ := conn.ZCard(ctx, RATE_LIMIT_KEY+":queue:"+queueName).Result()
+			c.Expect(count, Equals, int64(0))
+
+			SetQueueRateLimit(queueName, nil)
+		})
+
+		c.Specify("limits jobs separately per key derived from their args", func() {
+			SetClassRateLimit("Export", ConcurrentLimit{Limit: 1, Key: func(message *Msg) string {
+				return message.Args().GetIndex(0).MustString()
+			}})
+
+			// Another process runs a job for acme
+			ConcurrentLimit{Limit: 1}.Acquire(ctx, conn, RATE_LIMIT_KEY+":class:Export:acme", "1")
+
+			other, _ := NewMsg("{\"jid\":\"2\",\"class\":\"Export\",\"args\":[\"initech\"]}")
+			same, _ := NewMsg("{\"jid\":\"3\",\"class\":\"Export\",\"args\":[\"acme\"]}")
+
+			worker.process(other)
+			worker.process(same)
+			c.Expect(ran, Equals, 1)
+
+			scheduled, _ := conn.ZRange(ctx, SCHEDULED_JOBS_KEY, 0, -1).Result()
+			c.Expect(len(scheduled), Equals, 1)
+
+			message, _ := NewMsg(scheduled[0])
+			c.Expect(message.Jid(), Equals, "3")
+
+			SetClassRateLimit("Export", nil)
+		})
+
+		c.Specify("falls back to the shared limit when the key panics", func() {
+			SetClassRateLimit("Export", ConcurrentLimit{Limit: 1, Key: func(message *Msg) string {
+				return message.Args().GetIndex(0).MustString()
+			}})
+
+			// Another process runs a job without key
+			ConcurrentLimit{Limit: 1}.Acquire(ctx, conn, RATE_LIMIT_KEY+":class:Export", "1")
+
+			message, _ := NewMsg("{\"jid\":\"2\",\"class\":\"Export\",\"args\":[42]}")
+			c.Expect(worker.process(message), IsTrue)
+			c.Expect(ran, Equals, 0)
+
+			scheduled, _ := conn.ZCard(ctx, SCHEDULED_JOBS_KEY).Result()
+			c.Expect(scheduled, Equals, int64(1))
+
+			SetClassRateLimit("Export", nil)
+		})
+
+		c.Specify("renews leases while jobs run", func() {
+			SetQueueRateLimit(queueName, ConcurrentLimit{Limit: 1, Lease: 30 * time.Millisecond})
+
+			var wait time.Duration
+			manager := defaultServer.newManager(queueName, func(message *Msg) {
+				time.Sleep(100 * time.Millisecond)
+				wait, _ = ConcurrentLimit{Limit: 1}.Acquire(ctx, conn, RATE_LIMIT_KEY+":queue:"+queueName, "2")
+			}, 1)
+
+			message, _ := NewMsg("{\"jid\":\"1\",\"args\":[]}")
+			newWorker(manager).process(message)
+
+			c.Expect(wait > 0, IsTrue)
+
+			SetQueueRateLimit(queueName, nil)
+		})
+	})
+}
@@ -0,0 +1,155 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const DEFAULT_RESULT_EXPIRATION = time.Hour
+
+// outcome is what is stored and published for a job: the value it returned,
+// or the state it ended in and its error when it failed for good.
+type outcome struct {
+	Value json.RawMessage `json:"value,omitempty"`
+	State string          `json:"state,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// WithResult turns a job returning a value into a job function for Process.
+// The value is serialized to JSON and stored under the JID of the job, for
+// Result and AwaitResult. Jobs which fail for good store their error instead.
+func WithResult(job func(message *Msg) interface{}) jobFunc {
+	return func(message *Msg) {
+		value, err := json.Marshal(job(message))
+		if err == nil {
+			err = storeOutcome(message.Context(), serverOf(message).config, message.Jid(), &outcome{Value: value})
+		}
+		if err != nil {
+			Logger.Errorln("failed to store result of", message.Jid(), ":", err)
+		}
+	}
+}
+
+// settleResult stores the outcome of a job which failed for good, dying,
+// failing without a retry, expiring or being discarded, so that awaiting its
+// result returns.
+// A job which died also fails, which doesn't overwrite its outcome.
+func (s *Server) settleResult(event jobEvent, message *Msg, err error) {
+	var state string
+	switch event {
+	case jobDied:
+		state = STATUS_DEAD
+	case jobExpired:
+		state = STATUS_EXPIRED
+	case jobDiscarded:
+		state = STATUS_DISCARDED
+	case jobFailed:
+		if message.deferred || message.settled {
+			return
+		}
+		state = STATUS_FAILED
+	default:
+		return
+	}
+	message.settled = true
+
+	result := &outcome{State: state}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	if err := storeOutcome(context.Background(), s.config, message.Jid(), result); err != nil {
+		Logger.Errorln("failed to store result of", message.Jid(), ":", err)
+	}
+}
+
+// Result returns the JSON result of a job, or nil when it has none yet or
+// it expired. A job which failed for good returns a *JobFailedError.
+func Result(ctx context.Context, jid string) (json.RawMessage, error) {
+	return defaultClient.Result(ctx, jid)
+}
+
+// AwaitResult blocks until a job stores its result, or fails for good, or
+// ctx is done.
+func AwaitResult(ctx context.Context, jid string) (json.RawMessage, error) {
+	return defaultClient.AwaitResult(ctx, jid)
+}
+
+// Result returns the JSON result of a job
+func (c *Client) Result(ctx context.Context, jid string) (json.RawMessage, error) {
+	result, err := c.config.Client.Get(ctx, resultKey(c.config, jid)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseOutcome(jid, result)
+}
+
+// AwaitResult blocks until a job stores its result or ctx is done. Results
+// are published when stored, so it doesn't poll.
+func (c *Client) AwaitResult(ctx context.Context, jid string) (json.RawMessage, error) {
+	key := resultKey(c.config, jid)
+
+	pubsub := c.config.Client.Subscribe(ctx, key)
+	defer pubsub.Close()
+
+	// Once subscribed, a result stored after the lookup below is published
+	// to us.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return nil, err
+	}
+
+	if result, err := c.Result(ctx, jid); err != nil || result != nil {
+		return result, err
+	}
+
+	select {
+	case message, ok := <-pubsub.Channel():
+		if !ok {
+			return nil, errors.New("result subscription closed")
+		}
+		return parseOutcome(jid, message.Payload)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func storeOutcome(ctx context.Context, config *WorkerConfig, jid string, outcome *outcome) error {
+	result, err := json.Marshal(outcome)
+	if err != nil {
+		return err
+	}
+
+	key := resultKey(config, jid)
+
+	pipe := config.Client.TxPipeline()
+	pipe.Set(ctx, key, result, config.ResultExpiration)
+	pipe.Publish(ctx, key, result)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func parseOutcome(jid, payload string) (json.RawMessage, error) {
+	var result outcome
+	if err := json.Unmarshal([]byte(payload), &result); err != nil {
+		return nil, err
+	}
+
+	if result.State != "" {
+		return nil, &JobFailedError{Jid: jid, State: result.State, Message: result.Error}
+	}
+
+	return result.Value, nil
+}
+
+func resultKey(config *WorkerConfig, jid string) string {
+	return config.Namespace + RESULT_KEY + ":" + jid
+}
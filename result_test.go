@@ -0,0 +1,145 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func ResultSpec(c gospec.Context) {
+	const queueName = "queue-result"
+
+	ctx := context.Background()
+
+	sum := WithResult(func(message *Msg) interface{} {
+		a, _ := message.Args().GetIndex(0).Int()
+		b, _ := message.Args().GetIndex(1).Int()
+		return map[string]int{"sum": a + b}
+	})
+
+	process := func(jid string) {
+		message, _ := NewMsg("{\"jid\":\"" + jid + "\",\"args\":[1,2]}")
+		worker := newWorker(defaultServer.newManager(queueName, sum, 1))
+		worker.process(message)
+	}
+
+	fail := func(payload string) {
+		message, _ := NewMsg(payload)
+		worker := newWorker(defaultServer.newManager(queueName, func(message *Msg) {
+			panic("AHHHH")
+		}, 1))
+		worker.process(message)
+	}
+
+	c.Specify("Result", func() {
+		c.Specify("returns the value returned by the job", func() {
+			process("1")
+
+			result, err := Result(ctx, "1")
+			c.Expect(err, IsNil)
+			c.Expect(string(result), Equals, "{\"sum\":3}")
+
+			ttl, _ := Config.Client.TTL(ctx, "result:1").Result()
+			c.Expect(ttl > 59*time.Minute, IsTrue)
+		})
+
+		c.Specify("returns the error of jobs which failed without a retry", func() {
+			fail("{\"jid\":\"1\",\"args\":[]}")
+
+			result, err := Result(ctx, "1")
+			c.Expect(result == nil, IsTrue)
+			failed, ok := err.(*JobFailedError)
+			c.Expect(ok, IsTrue)
+			c.Expect(failed.State, Equals, STATUS_FAILED)
+			c.Expect(failed.Message, Equals, "AHHHH")
+		})
+
+		c.Specify("returns the error of dead jobs", func() {
+			fail("{\"jid\":\"1\",\"args\":[],\"retry\":true,\"retry_max\":1,\"retry_count\":1}")
+
+			_, err := Result(ctx, "1")
+			failed, ok := err.(*JobFailedError)
+			c.Expect(ok, IsTrue)
+			c.Expect(failed.State, Equals, STATUS_DEAD)
+			c.Expect(failed.Message, Equals, "AHHHH")
+		})
+
+		c.Specify("returns the error of discarded jobs", func() {
+			message, _ := NewMsg("{\"jid\":\"1\",\"args\":[],\"retry\":true}")
+			worker := newWorker(defaultServer.newManager(queueName, WithResult(func(message *Msg) interface{} {
+				panic(Discard(errors.New("no longer relevant")))
+			}), 1))
+			worker.process(message)
+
+			_, err := Result(ctx, "1")
+			failed, ok := err.(*JobFailedError)
+			c.Expect(ok, IsTrue)
+			c.Expect(failed.State, Equals, STATUS_DISCARDED)
+			c.Expect(failed.Message, Equals, "no longer relevant")
+		})
+
+		c.Specify("returns nil for jobs which will be retried", func() {
+			fail("{\"jid\":\"1\",\"args\":[],\"retry\":true}")
+
+			result, err := Result(ctx, "1")
+			c.Expect(err, IsNil)
+			c.Expect(result == nil, IsTrue)
+		})
+
+		c.Specify("returns nil before the job is done", func() {
+			result, err := Result(ctx, "1")
+			c.Expect(err, IsNil)
+			c.Expect(result == nil, IsTrue)
+		})
+	})
+
+	c.Specify("AwaitResult", func() {
+		c.Specify("returns results stored before", func() {
+			process("1")
+
+			result, err := AwaitResult(ctx, "1")
+			c.Expect(err, IsNil)
+			c.Expect(string(result), Equals, "{\"sum\":3}")
+		})
+
+		c.Specify("waits for the job to be done", func() {
+			go (func() {
+				time.Sleep(50 * time.Millisecond)
+				process("1")
+			})()
+
+			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+
+			result, err := AwaitResult(ctx, "1")
+			c.Expect(err, IsNil)
+			c.Expect(string(result), Equals, "{\"sum\":3}")
+		})
+
+		c.Specify("returns an error once the job dies", func() {
+			go (func() {
+				time.Sleep(50 * time.Millisecond)
+				fail("{\"jid\":\"1\",\"args\":[],\"retry\":true,\"retry_max\":1,\"retry_count\":1}")
+			})()
+
+			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+
+			result, err := AwaitResult(ctx, "1")
+			c.Expect(result == nil, IsTrue)
+			c.Expect(err.Error(), Equals, "job 1 dead: AHHHH")
+		})
+
+		c.Specify("gives up once its context is done", func() {
+			ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+
+			result, err := AwaitResult(ctx, "1")
+			c.Expect(err, Equals, context.DeadlineExceeded)
+			c.Expect(result == nil, IsTrue)
+		})
+	})
+}
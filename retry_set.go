@@ -0,0 +1,54 @@
+package workers
+
+import (
+	"context"
+	"errors"
+)
+
+// RetrySet holds failed jobs waiting for their next retry
+type RetrySet struct {
+	sortedSet
+}
+
+// NewRetrySet returns the retry set of the configured namespace
+func NewRetrySet() *RetrySet {
+	return defaultServer.RetrySet()
+}
+
+// RetrySet returns the retry set of the server's namespace
+func (s *Server) RetrySet() *RetrySet {
+	return &RetrySet{sortedSet{RETRY_KEY, s}}
+}
+
+// Kill moves an entry to the dead set, running the death hooks
+func (r *RetrySet) Kill(ctx context.Context, entry *SortedEntry) error {
+	_, err := r.kill(ctx, entry)
+	return err
+}
+
+// kill moves an entry to the dead set, and reports whether it was still in
+// the set.
+func (r *RetrySet) kill(ctx context.Context, entry *SortedEntry) (bool, error) {
+	removed, err := r.delete(ctx, entry)
+	// Someone else already moved or deleted this entry.
+	if err != nil || !removed {
+		return false, err
+	}
+
+	message, _ := NewMsg(entry.OriginalJson())
+	if err := r.server.DeadSet().Kill(ctx, message); err != nil {
+		return false, err
+	}
+
+	queue, _ := message.Get("queue").String()
+	errorMessage, _ := message.Get("error_message").String()
+	r.server.runJobHooks(jobDied, queue, message, errors.New(errorMessage), 0)
+
+	return true, nil
+}
+
+// KillWhere moves every entry matching filter to the dead set, and returns
+// how many were moved.
+func (r *RetrySet) KillWhere(ctx context.Context, filter EntryFilter) (int, error) {
+	return r.each(ctx, filter, r.kill)
+}
@@ -0,0 +1,139 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+	"github.com/redis/go-redis/v9"
+)
+
+func RetrySetSpec(c gospec.Context) {
+	ctx := context.Background()
+
+	was := Config.Namespace
+	Config.Namespace = "prod:"
+
+	conn := Config.Client
+	retries := NewRetrySet()
+
+	now := nowToSecondsWithNanoPrecision()
+	for i := 1; i <= 5; i++ {
+		class := "Send"
+		if i%2 == 0 {
+			class = "Export"
+		}
+		conn.ZAdd(ctx, "prod:"+RETRY_KEY, redis.Z{
+			Score:  now + float64(i),
+			Member: fmt.Sprintf("{\"jid\":\"%d\",\"class\":\"%s\",\"queue\":\"default\",\"retry_count\":1,\"error_message\":\"error %d\"}", i, class, i),
+		})
+	}
+
+	c.Specify("lists entries page by page", func() {
+		page, _ := retries.Page(ctx, 1, 2)
+		c.Expect(len(page), Equals, 2)
+		c.Expect(page[0].Jid(), Equals, "5")
+		c.Expect(page[1].Jid(), Equals, "4")
+
+		page, _ = retries.Page(ctx, 3, 2)
+		c.Expect(len(page), Equals, 1)
+		c.Expect(page[0].Jid(), Equals, "1")
+		c.Expect(page[0].At, Equals, now+1)
+	})
+
+	c.Specify("finds entries", func() {
+		c.Specify("by jid", func() {
+			entry, _ := retries.FindJid(ctx, "3")
+			c.Expect(entry.Jid(), Equals, "3")
+
+			entry, _ = retries.FindJid(ctx, "42")
+			c.Expect(entry, IsNil)
+		})
+
+		c.Specify("by class", func() {
+			entries, _ := retries.Find(ctx, EntryFilter{Class: "Export"})
+			c.Expect(len(entries), Equals, 2)
+		})
+
+		c.Specify("by error message", func() {
+			entries, _ := retries.Find(ctx, EntryFilter{ErrorMessage: "error 5"})
+			c.Expect(len(entries), Equals, 1)
+			c.Expect(entries[0].Jid(), Equals, "5")
+		})
+	})
+
+	c.Specify("retries an entry now", func() {
+		entry, _ := retries.FindJid(ctx, "3")
+
+		retries.Retry(ctx, entry)
+
+		size, _ := retries.Size(ctx)
+		queued, _ := conn.LRange(ctx, "prod:queue:default", 0, -1).Result()
+		c.Expect(size, Equals, int64(4))
+		c.Expect(len(queued), Equals, 1)
+	})
+
+	c.Specify("doesn't retry entries taken by someone else", func() {
+		entry, _ := retries.FindJid(ctx, "3")
+		retries.Delete(ctx, entry)
+
+		retried, err := retries.retry(ctx, entry)
+		c.Expect(err, IsNil)
+		c.Expect(retried, IsFalse)
+
+		queued, _ := conn.LLen(ctx, "prod:queue:default").Result()
+		c.Expect(queued, Equals, int64(0))
+	})
+
+	c.Specify("deletes an entry", func() {
+		entry, _ := retries.FindJid(ctx, "3")
+
+		retries.Delete(ctx, entry)
+
+		entry, _ = retries.FindJid(ctx, "3")
+		c.Expect(entry, IsNil)
+	})
+
+	c.Specify("kills an entry", func() {
+		entry, _ := retries.FindJid(ctx, "3")
+
+		retries.Kill(ctx, entry)
+
+		size, _ := retries.Size(ctx)
+		c.Expect(size, Equals, int64(4))
+
+		dead, _ := NewDeadSet().FindJid(ctx, "3")
+		c.Expect(dead.Jid(), Equals, "3")
+	})
+
+	c.Specify("bulk operations", func() {
+		c.Specify("retries by filter", func() {
+			count, _ := retries.RetryWhere(ctx, EntryFilter{Class: "Send"})
+			c.Expect(count, Equals, 3)
+
+			queued, _ := conn.LLen(ctx, "prod:queue:default").Result()
+			c.Expect(queued, Equals, int64(3))
+		})
+
+		c.Specify("deletes by filter", func() {
+			count, _ := retries.DeleteWhere(ctx, EntryFilter{Class: "Export"})
+			c.Expect(count, Equals, 2)
+
+			size, _ := retries.Size(ctx)
+			c.Expect(size, Equals, int64(3))
+		})
+
+		c.Specify("kills by filter", func() {
+			count, _ := retries.KillWhere(ctx, EntryFilter{})
+			c.Expect(count, Equals, 5)
+
+			size, _ := retries.Size(ctx)
+			dead, _ := NewDeadSet().Size(ctx)
+			c.Expect(size, Equals, int64(0))
+			c.Expect(dead, Equals, int64(5))
+		})
+	})
+
+	Config.Namespace = was
+}
@@ -4,17 +4,21 @@ import (
 	"context"
 	"fmt"
 	"github.com/redis/go-redis/v9"
-	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type scheduled struct {
-	keys   []string
-	closed chan bool
-	exit   chan bool
+	config   *WorkerConfig
+	keys     []string
+	closed   chan bool
+	exit     chan bool
+	polledAt int64
 }
 
 func (s *scheduled) start(ctx context.Context) {
+	atomic.StoreInt64(&s.polledAt, time.Now().Unix())
+
 	go (func() {
 		for {
 			select {
@@ -24,8 +28,9 @@ func (s *scheduled) start(ctx context.Context) {
 			}
 
 			s.poll(ctx)
+			atomic.StoreInt64(&s.polledAt, time.Now().Unix())
 
-			time.Sleep(time.Duration(Config.PoolInterval) * time.Second)
+			time.Sleep(time.Duration(s.config.PoolInterval) * time.Second)
 		}
 	})()
 }
@@ -34,13 +39,26 @@ func (s *scheduled) quit() {
 	close(s.closed)
 }
 
+// polling reports whether the scheduler is running and polled within the
+// last two intervals.
+func (s *scheduled) polling() bool {
+	select {
+	case <-s.closed:
+		return false
+	default:
+	}
+
+	polledAt := time.Unix(atomic.LoadInt64(&s.polledAt), 0)
+	return time.Since(polledAt) <= 2*time.Duration(s.config.PoolInterval)*time.Second
+}
+
 func (s *scheduled) poll(ctx context.Context) {
-	conn := Config.Client
+	conn := s.config.Client
 
 	now := nowToSecondsWithNanoPrecision()
 
 	for _, key := range s.keys {
-		key = Config.Namespace + key
+		key = s.config.Namespace + key
 		for {
 			opt := &redis.ZRangeBy{
 				Min:    "-inf",
@@ -56,17 +74,14 @@ func (s *scheduled) poll(ctx context.Context) {
 
 			message, _ := NewMsg(messages[0])
 
-			if removed, _ := conn.ZRem(ctx, key, messages[0]).Result(); removed > 0 {
-				queue, _ := message.Get("queue").String()
-				queue = strings.TrimPrefix(queue, Config.Namespace)
-				message.Set("enqueued_at", nowToSecondsWithNanoPrecision())
-
-				conn.LPush(ctx, Config.Namespace+"queue:"+queue, message.ToJson())
+			if _, err := requeue(ctx, s.config, key, messages[0], message); err != nil {
+				Logger.Errorln("failed to enqueue scheduled job", message.Jid(), ":", err)
+				break
 			}
 		}
 	}
 }
 
-func newScheduled(keys ...string) *scheduled {
-	return &scheduled{keys, make(chan bool), make(chan bool)}
+func newScheduled(config *WorkerConfig, keys ...string) *scheduled {
+	return &scheduled{config, keys, make(chan bool), make(chan bool), 0}
 }
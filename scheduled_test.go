@@ -10,7 +10,7 @@ import (
 
 func ScheduledSpec(c gospec.Context) {
 	ctx := context.Background()
-	scheduled := newScheduled(RETRY_KEY)
+	scheduled := newScheduled(Config, RETRY_KEY)
 
 	was := Config.Namespace
 	Config.Namespace = "prod:"
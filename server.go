@@ -0,0 +1,516 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Server processes the queues of one redis configuration. Several servers can
+// run in the same process, e.g. to consume from two redis clusters, while
+// sharing the package Logger. The package-level functions use a default
+// server set up with Configure.
+type Server struct {
+	// Middleware wraps the jobs of queues processed without their own
+	// middleware.
+	Middleware *Middlewares
+
+	config         *WorkerConfig
+	managers       map[string]*manager
+	draining       map[*manager]bool
+	schedule       *scheduled
+	fleet          *fleetPoller
+	control        *controller
+	access         sync.Mutex
+	stateM         sync.RWMutex
+	started        bool
+	quiet          bool
+	phase          int32
+	stopped        chan bool
+	requeuedOnQuit []string
+	runningOnQuit  []string
+	beforeStart    []func()
+	afterStart     []func()
+	beforeQuit     []func()
+	duringDrain    []func()
+	afterQuit      []func()
+	hooksM         sync.RWMutex
+	jobHooks       map[jobEvent][]JobHook
+	limitsM        sync.RWMutex
+	queueLimits    map[string]RateLimiter
+	classLimits    map[string]RateLimiter
+	backoffsM      sync.RWMutex
+	queueBackoffs  map[string]Backoff
+	classBackoffs  map[string]Backoff
+	metrics        *metrics
+}
+
+// New returns a server for the given options, with the default middleware.
+func New(options Options) *Server {
+	return newServer(newConfig(options), defaultMiddleware())
+}
+
+func newServer(config *WorkerConfig, mids *Middlewares) *Server {
+	return &Server{
+		Middleware:    mids,
+		config:        config,
+		managers:      make(map[string]*manager),
+		draining:      make(map[*manager]bool),
+		jobHooks:      make(map[jobEvent][]JobHook),
+		queueLimits:   make(map[string]RateLimiter),
+		classLimits:   make(map[string]RateLimiter),
+		queueBackoffs: make(map[string]Backoff),
+		classBackoffs: make(map[string]Backoff),
+		metrics:       newMetrics(),
+	}
+}
+
+// middleware returns the middleware of the server, falling back to the
+// package Middleware for the default server.
+func (s *Server) middleware() *Middlewares {
+	if s.Middleware == nil {
+		return Middleware
+	}
+	return s.Middleware
+}
+
+// Process registers a job function for a queue. Once workers are started,
+// the queue starts being processed right away, after draining the previous
+// manager of the queue if any.
+func (s *Server) Process(queue string, job jobFunc, concurrency int, mids ...Action) {
+	s.access.Lock()
+
+	m := s.newManager(queue, job, concurrency, mids...)
+
+	old, ok := s.managers[queue]
+	if !ok || !s.started {
+		s.setManager(queue, m)
+		if s.started {
+			s.startAdded(m)
+		}
+		s.access.Unlock()
+		return
+	}
+
+	// The previous manager drains without holding access, so other calls,
+	// like Quit, don't wait for it. Its jobs must be done before the new
+	// manager starts, as it would fetch them again from the in-progress
+	// queue.
+	s.setManager(queue, nil)
+	s.draining[old] = true
+	s.access.Unlock()
+
+	old.quit()
+	old.Wait()
+
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	delete(s.draining, old)
+
+	// Process was called again for the queue while draining, which wins
+	if _, ok := s.managers[queue]; ok {
+		return
+	}
+
+	s.setManager(queue, m)
+	if s.started {
+		s.startAdded(m)
+	}
+}
+
+// startAdded starts a manager added while workers are running, keeping it
+// from fetching while quiet.
+func (s *Server) startAdded(m *manager) {
+	m.start()
+	if s.quiet {
+		m.prepare()
+	}
+}
+
+// StopProcessing stops fetching messages from a queue, waits for its
+// in-flight jobs to finish and removes it, without affecting other queues.
+func (s *Server) StopProcessing(queue string) error {
+	s.access.Lock()
+	m, ok := s.managers[queue]
+	if ok {
+		s.setManager(queue, nil)
+	}
+	running := s.started
+	if ok && running {
+		s.draining[m] = true
+	}
+	s.access.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown queue %s", queue)
+	}
+
+	if running {
+		m.quit()
+		m.Wait()
+
+		s.access.Lock()
+		delete(s.draining, m)
+		s.access.Unlock()
+	}
+
+	return nil
+}
+
+// setManager registers the manager of a queue, or removes it when nil. It
+// must be called with access held.
+func (s *Server) setManager(queue string, m *manager) {
+	s.stateM.Lock()
+	defer s.stateM.Unlock()
+
+	if m == nil {
+		delete(s.managers, queue)
+	} else {
+		s.managers[queue] = m
+	}
+}
+
+// Run starts the server and blocks until it is told to exit via unix signal
+func (s *Server) Run() {
+	s.Start()
+	go handleSignals(s)
+	s.waitForQuit()
+}
+
+// RunContext starts workers and blocks until ctx is cancelled or Quit is
+// called, draining in-flight jobs before returning. Unlike Run, it leaves
+// signal handling to the caller. It returns a *ShutdownError when the
+// shutdown timeout expired with jobs still running.
+func (s *Server) RunContext(ctx context.Context) error {
+	s.Start()
+
+	s.access.Lock()
+	stopped := s.stopped
+	s.access.Unlock()
+
+	select {
+	case <-ctx.Done():
+		s.Quit()
+	case <-stopped:
+	}
+
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	if len(s.requeuedOnQuit) > 0 || len(s.runningOnQuit) > 0 {
+		return &ShutdownError{s.requeuedOnQuit, s.runningOnQuit}
+	}
+
+	return nil
+}
+
+func (s *Server) ResetManagers() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	if s.started {
+		return errors.New("Cannot reset worker managers while workers are running")
+	}
+
+	s.stateM.Lock()
+	s.managers = make(map[string]*manager)
+	s.stateM.Unlock()
+
+	return nil
+}
+
+func (s *Server) Start() {
+	ctx := context.Background()
+	s.access.Lock()
+
+	if s.started {
+		s.access.Unlock()
+		return
+	}
+
+	runHooks(s.beforeStart)
+	s.startSchedule(ctx)
+	s.startManagers()
+	s.startFleet(ctx)
+	s.startControl(ctx)
+
+	s.started = true
+	s.stopped = make(chan bool)
+	atomic.StoreInt32(&s.phase, phaseRunning)
+
+	afterStart := s.afterStart
+	s.access.Unlock()
+
+	// Hooks run unlocked, as they may call back into the server
+	runHooks(afterStart)
+}
+
+// Quiet stops fetching new messages and polling scheduled jobs, and lets
+// in-flight jobs finish. The process keeps running, along with its stats
+// server and control channel, until Quit is called.
+func (s *Server) Quiet() {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	if !s.started || s.quiet {
+		return
+	}
+
+	Logger.Infoln("quieting workers")
+	atomic.StoreInt32(&s.phase, phaseDraining)
+
+	for _, m := range s.managers {
+		m.prepare()
+	}
+	s.quitSchedule()
+
+	s.quiet = true
+}
+
+// Quit stops fetching messages and waits for in-flight jobs to finish. When
+// the shutdown timeout expires first, the context of jobs still running is
+// cancelled. Jobs which then fail are pushed back to the front of their queue,
+// and Quit returns their JIDs. So are jobs still running after a grace
+// period: they keep their workers draining, but whatever they end up doing,
+// their messages run again.
+func (s *Server) Quit() []string {
+	s.access.Lock()
+
+	if !s.started {
+		s.access.Unlock()
+		return nil
+	}
+
+	atomic.StoreInt32(&s.phase, phaseDraining)
+	runHooks(s.beforeQuit)
+
+	s.quitControl()
+	s.quitFleet()
+	s.quitManagers()
+	s.quitSchedule()
+	runHooks(s.duringDrain)
+	requeued, running := s.waitForExit()
+	s.requeuedOnQuit = requeued
+	s.runningOnQuit = running
+
+	s.started = false
+	s.quiet = false
+	if len(s.draining) == 0 {
+		atomic.StoreInt32(&s.phase, phaseStopped)
+	}
+
+	afterQuit := s.afterQuit
+	stopped := s.stopped
+	s.access.Unlock()
+
+	// Hooks run unlocked, as they may call back into the server
+	runHooks(afterQuit)
+	close(stopped)
+
+	return requeued
+}
+
+// StatsServer serves the stats, health and metrics endpoints of the server.
+// They are read-only, the Control handler isn't served.
+func (s *Server) StatsServer(port int) {
+	mux := http.NewServeMux()
+	s.serveStats(mux, port)
+}
+
+func (s *Server) serveStats(mux *http.ServeMux, port int) {
+	mux.HandleFunc("/stats", s.Stats)
+	mux.HandleFunc("/stats/history", s.StatsHistoryHandler)
+	mux.HandleFunc("/healthz", s.Healthz)
+	mux.HandleFunc("/readyz", s.Readyz)
+	mux.HandleFunc("/metrics", s.Metrics)
+
+	Logger.Infoln("Stats are available at", fmt.Sprint("http://localhost:", port, "/stats"))
+
+	if err := http.ListenAndServe(fmt.Sprint(":", port), mux); err != nil {
+		Logger.Error("failed to start stats server", err)
+	}
+}
+
+func (s *Server) startSchedule(ctx context.Context) {
+	if s.schedule == nil {
+		s.stateM.Lock()
+		s.schedule = newScheduled(s.config, RETRY_KEY, SCHEDULED_JOBS_KEY)
+		s.stateM.Unlock()
+	}
+
+	s.schedule.start(ctx)
+}
+
+func (s *Server) quitSchedule() {
+	if s.schedule != nil {
+		s.schedule.quit()
+		s.stateM.Lock()
+		s.schedule = nil
+		s.stateM.Unlock()
+	}
+}
+
+func (s *Server) startControl(ctx context.Context) {
+	s.control = newController(s)
+	s.control.start(ctx)
+}
+
+func (s *Server) quitControl() {
+	if s.control != nil {
+		s.control.quit()
+		s.control = nil
+	}
+}
+
+func (s *Server) startFleet(ctx context.Context) {
+	s.fleet = newFleetPoller(s)
+	s.fleet.start(ctx)
+}
+
+func (s *Server) quitFleet() {
+	if s.fleet != nil {
+		s.fleet.quit()
+		s.fleet = nil
+	}
+}
+
+func (s *Server) startManagers() {
+	for _, manager := range s.managers {
+		manager.start()
+	}
+}
+
+func (s *Server) quitManagers() {
+	for _, m := range s.managers {
+		go (func(m *manager) { m.quit() })(m)
+	}
+}
+
+// currentManagers returns the managers of the server, for readers which
+// can't wait on access, like the stats and health endpoints.
+func (s *Server) currentManagers() map[string]*manager {
+	s.stateM.RLock()
+	defer s.stateM.RUnlock()
+
+	managers := make(map[string]*manager, len(s.managers))
+	for queue, m := range s.managers {
+		managers[queue] = m
+	}
+	return managers
+}
+
+// waitForQuit blocks until Quit returns
+func (s *Server) waitForQuit() {
+	s.access.Lock()
+	stopped := s.stopped
+	s.access.Unlock()
+
+	if stopped != nil {
+		<-stopped
+	}
+}
+
+// abandonGrace is how long jobs have to return once their context is
+// cancelled by the shutdown timeout.
+var abandonGrace = time.Second
+
+// waitForExit waits for the managers to exit, up to the shutdown timeout. It
+// then cancels the jobs still running, pushes back the ones still running
+// after abandonGrace, and returns the JIDs of the ones requeued and of the
+// ones still running.
+func (s *Server) waitForExit() ([]string, []string) {
+	running := make([]*manager, 0, len(s.managers)+len(s.draining))
+	for _, manager := range s.managers {
+		running = append(running, manager)
+	}
+	// Managers replaced or removed while running are still draining
+	for manager := range s.draining {
+		running = append(running, manager)
+	}
+
+	done := make(chan bool)
+	go (func() {
+		for _, manager := range running {
+			manager.Wait()
+		}
+		close(done)
+	})()
+
+	exited := func() {
+		for _, manager := range running {
+			delete(s.draining, manager)
+		}
+	}
+
+	if s.config.ShutdownTimeout == 0 {
+		<-done
+		exited()
+		return nil, nil
+	}
+
+	select {
+	case <-done:
+		exited()
+		return nil, nil
+	case <-time.After(s.config.ShutdownTimeout):
+	}
+
+	// Workers push the jobs which fail once cancelled back to their queue
+	for _, manager := range running {
+		manager.cancel()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(abandonGrace):
+	}
+
+	// The messages of jobs still running are pushed back to their queue,
+	// and their workers left draining
+	var requeued, stillRunning []string
+	for _, manager := range running {
+		r, sr := manager.abandon()
+		requeued = append(requeued, r...)
+		stillRunning = append(stillRunning, sr...)
+
+		s.draining[manager] = true
+	}
+
+	// New managers take over their queues, so that starting again doesn't
+	// wait for jobs which may never return
+	for queue, manager := range s.managers {
+		if s.draining[manager] {
+			s.setManager(queue, manager.renew(queue))
+		}
+	}
+
+	go (func() {
+		<-done
+
+		s.access.Lock()
+		defer s.access.Unlock()
+
+		exited()
+		if !s.started && len(s.draining) == 0 {
+			atomic.StoreInt32(&s.phase, phaseStopped)
+		}
+	})()
+
+	Logger.Warnln("shutdown timeout expired, requeued", len(requeued), "jobs:", requeued, "still running:", stillRunning)
+
+	return requeued, stillRunning
+}
+
+// serverOf returns the server processing a message, or the default server
+// for messages built outside of a worker.
+func serverOf(message *Msg) *Server {
+	if message.server != nil {
+		return message.server
+	}
+	return defaultServer
+}
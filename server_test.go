@@ -0,0 +1,69 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func ServerSpec(c gospec.Context) {
+	const queueName = "queue-server"
+
+	ctx := context.Background()
+	options := Options{
+		RedisClient: Config.Client,
+		ProcessID:   "1",
+		Namespace:   "other",
+	}
+
+	c.Specify("New", func() {
+		c.Specify("processes jobs enqueued in its namespace only", func() {
+			processed := make(chan string)
+
+			server := New(options)
+			server.Process(queueName, func(message *Msg) {
+				processed <- message.Jid()
+			}, 1)
+			server.Start()
+
+			Enqueue(queueName, "Add", []int{1, 2})
+			jid, _ := NewClient(Options{RedisClient: Config.Client, Namespace: "other"}).Enqueue(queueName, "Add", []int{1, 2})
+
+			c.Expect(<-processed, Equals, jid)
+
+			server.Quit()
+
+			count, _ := Config.Client.LLen(ctx, "queue:"+queueName).Result()
+			c.Expect(count, Equals, int64(1))
+			c.Expect(server.GetStats().Processed, Equals, 1)
+			c.Expect(GetStats().Processed, Equals, 0)
+		})
+
+		c.Specify("has its own middleware", func() {
+			server := New(options)
+
+			c.Expect(server.Middleware, Not(Equals), Middleware)
+			c.Expect(len(server.Middleware.actions), Equals, len(Middleware.actions))
+		})
+
+		c.Specify("doesn't share queues with the default server", func() {
+			server := New(options)
+			server.Process(queueName, myJob, 1)
+
+			_, ok := defaultServer.managers[queueName]
+			c.Expect(len(server.managers), Equals, 1)
+			c.Expect(ok, IsFalse)
+		})
+
+		c.Specify("doesn't share backoffs with the default server", func() {
+			server := New(options)
+			server.SetQueueBackoff(queueName, ConstantBackoff{time.Minute})
+
+			message, _ := NewMsg("{\"jid\":\"2\",\"class\":\"Add\"}")
+			c.Expect(server.backoffFor(queueName, message), Equals, ConstantBackoff{time.Minute})
+			c.Expect(defaultServer.backoffFor(queueName, message), IsNil)
+		})
+	})
+}
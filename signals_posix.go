@@ -8,14 +8,16 @@ import (
 	"syscall"
 )
 
-func handleSignals() {
+func handleSignals(s *Server) {
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGINT, syscall.SIGTERM, syscall.SIGTSTP)
 
 	for sig := range signals {
 		switch sig {
 		case syscall.SIGINT, syscall.SIGUSR1, syscall.SIGTERM:
-			Quit()
+			s.Quit()
+		case syscall.SIGTSTP:
+			s.Quiet()
 		}
 	}
 }
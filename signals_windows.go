@@ -6,14 +6,14 @@ import (
 	"syscall"
 )
 
-func handleSignals() {
+func handleSignals(s *Server) {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
 	for sig := range signals {
 		switch sig {
 		case syscall.SIGINT, syscall.SIGTERM:
-			Quit()
+			s.Quit()
 		}
 	}
 }
@@ -0,0 +1,271 @@
+package workers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SortedEntry is a job stored in one of the sorted sets (retry, schedule or
+// dead), along with its score.
+type SortedEntry struct {
+	*Msg
+	At float64
+}
+
+// EntryFilter selects entries of a sorted set. Empty fields match any entry
+// and ErrorMessage matches any error message containing it.
+type EntryFilter struct {
+	Jid          string
+	Class        string
+	ErrorMessage string
+}
+
+type sortedSet struct {
+	name   string
+	server *Server
+}
+
+func (s *sortedSet) key() string {
+	return s.server.config.Namespace + s.name
+}
+
+// Size returns the number of entries in the set
+func (s *sortedSet) Size(ctx context.Context) (int64, error) {
+	return s.server.config.Client.ZCard(ctx, s.key()).Result()
+}
+
+// List returns entries between start and stop, ordered by score with the
+// most recent entry first.
+func (s *sortedSet) List(ctx context.Context, start, stop int64) ([]*SortedEntry, error) {
+	results, err := s.server.config.Client.ZRevRangeWithScores(ctx, s.key(), start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*SortedEntry, 0, len(results))
+	for _, result := range results {
+		member, ok := result.Member.(string)
+		if !ok {
+			continue
+		}
+
+		message, err := NewMsg(member)
+		if err != nil {
+			Logger.Errorln("failed to create message from", member, ":", err)
+			continue
+		}
+
+		entries = append(entries, &SortedEntry{message, result.Score})
+	}
+
+	return entries, nil
+}
+
+// Page returns the entries of a page, starting at page 1
+func (s *sortedSet) Page(ctx context.Context, page, size int64) ([]*SortedEntry, error) {
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * size
+	return s.List(ctx, start, start+size-1)
+}
+
+// Find returns every entry matching filter
+func (s *sortedSet) Find(ctx context.Context, filter EntryFilter) ([]*SortedEntry, error) {
+	conn := s.server.config.Client
+
+	var entries []*SortedEntry
+	var cursor uint64
+	for {
+		results, next, err := conn.ZScan(ctx, s.key(), cursor, "", 100).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// Results alternate between members and their scores.
+		for i := 0; i+1 < len(results); i += 2 {
+			message, err := NewMsg(results[i])
+			if err != nil || !filter.matches(message) {
+				continue
+			}
+
+			score, _ := strconv.ParseFloat(results[i+1], 64)
+			entries = append(entries, &SortedEntry{message, score})
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return entries, nil
+		}
+	}
+}
+
+// FindJid returns the entry of a job, or nil when it isn't in the set
+func (s *sortedSet) FindJid(ctx context.Context, jid string) (*SortedEntry, error) {
+	entries, err := s.Find(ctx, EntryFilter{Jid: jid})
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// Delete removes an entry from the set
+func (s *sortedSet) Delete(ctx context.Context, entry *SortedEntry) error {
+	_, err := s.delete(ctx, entry)
+	return err
+}
+
+// delete removes an entry from the set, and reports whether it was still
+// there. As a removed entry won't run again, the next job of its partition
+// runs instead.
+func (s *sortedSet) delete(ctx context.Context, entry *SortedEntry) (bool, error) {
+	removed, err := s.server.config.Client.ZRem(ctx, s.key(), entry.OriginalJson()).Result()
+	if err != nil || removed == 0 {
+		return false, err
+	}
+
+	queue, _ := entry.Get("queue").String()
+	return true, completePartition(ctx, s.server.config, queue, entry.Msg)
+}
+
+// Clear removes every entry from the set. Entries of partitions are removed
+// one by one first, to let the next job of their partition run.
+func (s *sortedSet) Clear(ctx context.Context) error {
+	entries, err := s.Find(ctx, EntryFilter{})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.partition() == "" {
+			continue
+		}
+		if _, err := s.delete(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	return s.server.config.Client.Del(ctx, s.key()).Err()
+}
+
+// Retry removes an entry from the set and pushes it back to its queue
+func (s *sortedSet) Retry(ctx context.Context, entry *SortedEntry) error {
+	_, err := s.retry(ctx, entry)
+	return err
+}
+
+// retry moves an entry back to its queue, and reports whether it was still
+// in the set.
+func (s *sortedSet) retry(ctx context.Context, entry *SortedEntry) (bool, error) {
+	message, _ := NewMsg(entry.OriginalJson())
+	if count, err := message.Get("retry_count").Int(); err == nil && count > 0 {
+		message.Set("retry_count", count-1)
+	}
+
+	return requeue(ctx, s.server.config, s.key(), entry.OriginalJson(), message)
+}
+
+// RetryAll pushes every entry back to its queue
+func (s *sortedSet) RetryAll(ctx context.Context) error {
+	_, err := s.RetryWhere(ctx, EntryFilter{})
+	return err
+}
+
+// RetryWhere pushes every entry matching filter back to its queue, and
+// returns how many were moved.
+func (s *sortedSet) RetryWhere(ctx context.Context, filter EntryFilter) (int, error) {
+	return s.each(ctx, filter, s.retry)
+}
+
+// DeleteWhere removes every entry matching filter, and returns how many were
+// removed.
+func (s *sortedSet) DeleteWhere(ctx context.Context, filter EntryFilter) (int, error) {
+	return s.each(ctx, filter, s.delete)
+}
+
+// each applies f to every entry matching filter, and returns how many it
+// applied to. Entries already taken from the set by someone else, e.g. the
+// scheduler, aren't counted.
+func (s *sortedSet) each(ctx context.Context, filter EntryFilter, f func(context.Context, *SortedEntry) (bool, error)) (int, error) {
+	entries, err := s.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, entry := range entries {
+		applied, err := f(ctx, entry)
+		if err != nil {
+			return count, err
+		}
+		if applied {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (f EntryFilter) matches(message *Msg) bool {
+	if f.Jid != "" && message.Jid() != f.Jid {
+		return false
+	}
+	if class, _ := message.Get("class").String(); f.Class != "" && class != f.Class {
+		return false
+	}
+	if errorMessage, _ := message.Get("error_message").String(); !strings.Contains(errorMessage, f.ErrorMessage) {
+		return false
+	}
+	return true
+}
+
+// requeueScript removes a job from a sorted set and pushes it to its queue,
+// or to its partition as pushPartitionScript does, unless someone else
+// already removed it. It returns 0 when the job wasn't in the set.
+var requeueScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[2], ARGV[1]) == 0 then
+  return 0
+end
+if not KEYS[3] then
+  return redis.call('LPUSH', KEYS[1], ARGV[2])
+end
+if redis.call('LINDEX', KEYS[3], 0) == ARGV[3] then
+  return redis.call('LPUSH', KEYS[1], ARGV[2])
+end
+redis.call('RPUSH', KEYS[3], ARGV[3])
+redis.call('HSET', KEYS[4], ARGV[3], ARGV[2])
+if redis.call('LLEN', KEYS[3]) == 1 then
+  redis.call('LPUSH', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// requeue moves member out of the sorted set key and pushes message, its
+// updated payload, back to its queue in one step. It reports whether member
+// was still in the set.
+func requeue(ctx context.Context, config *WorkerConfig, key, member string, message *Msg) (bool, error) {
+	queue, _ := message.Get("queue").String()
+	queue = strings.TrimPrefix(queue, config.Namespace)
+	message.Set("enqueued_at", nowToSecondsWithNanoPrecision())
+
+	keys := []string{config.Namespace + "queue:" + queue, key}
+	if partition := message.partition(); partition != "" {
+		keys = append(keys, partitionKeys(config, queue, partition)[:2]...)
+	}
+
+	moved, err := requeueScript.Run(ctx, config.Client, keys, member, message.ToJson(), message.Jid()).Int()
+	if err != nil || moved == 0 {
+		return false, err
+	}
+
+	if message.tracked() {
+		if err := setStatus(ctx, config, message, STATUS_QUEUED); err != nil {
+			Logger.Errorln("failed to track status of", message.Jid(), ":", err)
+		}
+	}
+
+	return true, nil
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 )
 
 type stats struct {
@@ -14,17 +16,35 @@ type stats struct {
 	Failed    int         `json:"failed"`
 	Jobs      interface{} `json:"jobs"`
 	Enqueued  interface{} `json:"enqueued"`
+	Latency   interface{} `json:"latency"`
 	Retries   int64       `json:"retries"`
+	Paused    []string    `json:"paused"`
+	ByQueue   interface{} `json:"by_queue"`
+	ByClass   interface{} `json:"by_class"`
+}
+
+// StatCounts holds the processed and failed counters of a queue or a job
+// class, in total and for today (UTC).
+type StatCounts struct {
+	Processed      int64 `json:"processed"`
+	Failed         int64 `json:"failed"`
+	ProcessedToday int64 `json:"processed_today"`
+	FailedToday    int64 `json:"failed_today"`
 }
 
 // Stats writes stats on response writer
 func Stats(w http.ResponseWriter, req *http.Request) {
+	defaultServer.Stats(w, req)
+}
+
+// Stats writes the stats of the server on response writer
+func (s *Server) Stats(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	stats := getStats(ctx)
+	stats := s.getStats(ctx)
 
 	body, _ := json.MarshalIndent(stats, "", "  ")
 	fmt.Fprintln(w, string(body))
@@ -35,36 +55,63 @@ type WorkerStats struct {
 	Processed int               `json:"processed"`
 	Failed    int               `json:"failed"`
 	Enqueued  map[string]string `json:"enqueued"`
-	Retries   int64             `json:"retries"`
+	// Latency is how long the next job of each queue has been waiting, in
+	// seconds.
+	Latency map[string]float64 `json:"latency"`
+	Retries int64              `json:"retries"`
+	Paused  []string           `json:"paused"`
+	// ByQueue and ByClass hold the counters of each queue and job class
+	ByQueue map[string]*StatCounts `json:"by_queue"`
+	ByClass map[string]*StatCounts `json:"by_class"`
 }
 
 // GetStats returns workers stats
 func GetStats() *WorkerStats {
+	return defaultServer.GetStats()
+}
+
+// GetStats returns the stats of the server
+func (s *Server) GetStats() *WorkerStats {
 	ctx := context.TODO()
 
-	stats := getStats(ctx)
+	stats := s.getStats(ctx)
 	enqueued := map[string]string{}
 	if statsEnqueued, ok := stats.Enqueued.(map[string]string); ok {
 		enqueued = statsEnqueued
 	}
+	latency := map[string]float64{}
+	if statsLatency, ok := stats.Latency.(map[string]float64); ok {
+		latency = statsLatency
+	}
+	byQueue, _ := stats.ByQueue.(map[string]*StatCounts)
+	byClass, _ := stats.ByClass.(map[string]*StatCounts)
 
 	return &WorkerStats{
 		Processed: stats.Processed,
 		Failed:    stats.Failed,
 		Retries:   stats.Retries,
 		Enqueued:  enqueued,
+		Latency:   latency,
+		Paused:    stats.Paused,
+		ByQueue:   byQueue,
+		ByClass:   byClass,
 	}
 }
 
-func getStats(ctx context.Context) stats {
+func (s *Server) getStats(ctx context.Context) stats {
 	jobs := make(map[string][]*map[string]interface{})
 	enqueued := make(map[string]string)
+	latency := make(map[string]float64)
+	paused := make([]string, 0)
 
-	for _, m := range managers {
+	for _, m := range s.currentManagers() {
 		queue := m.queueName()
 		jobs[queue] = make([]*map[string]interface{}, 0)
 		enqueued[queue] = ""
-		for _, worker := range m.workers {
+		if m.isPaused() {
+			paused = append(paused, queue)
+		}
+		for _, worker := range m.currentWorkers() {
 			message := worker.currentMsg
 			startedAt := worker.startedAt
 
@@ -77,23 +124,29 @@ func getStats(ctx context.Context) stats {
 		}
 	}
 
+	sort.Strings(paused)
+
 	stats := stats{
 		0,
 		0,
 		jobs,
 		enqueued,
+		latency,
 		0,
+		paused,
+		nil,
+		nil,
 	}
 
-	conn := Config.Client
+	conn := s.config.Client
 
 	pipe := conn.TxPipeline()
-	pipe.Get(ctx, Config.Namespace+"stat:processed")
-	pipe.Get(ctx, Config.Namespace+"stat:failed")
-	pipe.ZCard(ctx, Config.Namespace+RETRY_KEY)
+	pipe.Get(ctx, s.config.Namespace+"stat:processed")
+	pipe.Get(ctx, s.config.Namespace+"stat:failed")
+	pipe.ZCard(ctx, s.config.Namespace+RETRY_KEY)
 
 	for key := range enqueued {
-		pipe.LLen(ctx, fmt.Sprintf("%squeue:%s", Config.Namespace, key))
+		pipe.LLen(ctx, fmt.Sprintf("%squeue:%s", s.config.Namespace, key))
 	}
 
 	results, err := pipe.Exec(ctx)
@@ -131,5 +184,230 @@ func getStats(ctx context.Context) stats {
 		}
 	}
 
+	stats.ByQueue, stats.ByClass = s.getStatCounts(ctx)
+
+	// The head of every queue is fetched in one pipeline. Empty queues only
+	// make their own LIndex return redis.Nil, so the error of Exec is
+	// ignored for the one of each command.
+	pipe = conn.Pipeline()
+	heads := make(map[string]*redis.StringCmd, len(enqueued))
+	for queue := range enqueued {
+		heads[queue] = pipe.LIndex(ctx, s.config.Namespace+"queue:"+queue, -1)
+	}
+	pipe.Exec(ctx)
+
+	for queue, head := range heads {
+		if l, err := headLatency(head); err == nil {
+			latency[queue] = l.Seconds()
+		} else {
+			Logger.Errorln("failed to retrieve latency of", queue, ":", err)
+		}
+	}
+
 	return stats
 }
+
+// getStatCounts reads the counters per queue and per class written by
+// incrementStats.
+func (s *Server) getStatCounts(ctx context.Context) (map[string]*StatCounts, map[string]*StatCounts) {
+	counts := map[string]map[string]*StatCounts{
+		"queue": make(map[string]*StatCounts),
+		"class": make(map[string]*StatCounts),
+	}
+
+	type countsCmd struct {
+		by     string
+		metric string
+		today  bool
+		cmd    *redis.MapStringStringCmd
+	}
+
+	today := time.Now().UTC().Format(STATS_DAY_LAYOUT)
+
+	pipe := s.config.Client.Pipeline()
+	cmds := make([]countsCmd, 0, 8)
+	for by := range counts {
+		for _, metric := range []string{"processed", "failed"} {
+			key := s.config.Namespace + "stat:" + metric + ":" + by
+			cmds = append(cmds,
+				countsCmd{by, metric, false, pipe.HGetAll(ctx, key)},
+				countsCmd{by, metric, true, pipe.HGetAll(ctx, key+":"+today)},
+			)
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		Logger.Errorln("failed to retrieve stats per queue and class:", err)
+		return counts["queue"], counts["class"]
+	}
+
+	for _, c := range cmds {
+		for field, value := range c.cmd.Val() {
+			stat, ok := counts[c.by][field]
+			if !ok {
+				stat = &StatCounts{}
+				counts[c.by][field] = stat
+			}
+
+			n, _ := strconv.ParseInt(value, 10, 64)
+			switch {
+			case c.metric == "processed" && !c.today:
+				stat.Processed = n
+			case c.metric == "processed":
+				stat.ProcessedToday = n
+			case !c.today:
+				stat.Failed = n
+			default:
+				stat.FailedToday = n
+			}
+		}
+	}
+
+	return counts["queue"], counts["class"]
+}
+
+// defaultStatsHistoryDays is how many days /stats/history returns when the
+// days parameter is missing
+const defaultStatsHistoryDays = 30
+
+// maxStatsHistoryDays bounds the days of a history, as each day is a key to
+// read and a row to return.
+const maxStatsHistoryDays = 365
+
+// DayStats holds the processed and failed counts of a day (UTC)
+type DayStats struct {
+	Date      string `json:"date"`
+	Processed int64  `json:"processed"`
+	Failed    int64  `json:"failed"`
+}
+
+// StatsHistory returns the processed and failed counts of the last days,
+// today included, oldest first, up to a year.
+func StatsHistory(days int) ([]DayStats, error) {
+	return defaultServer.StatsHistory(days)
+}
+
+// StatsHistory returns the processed and failed counts of the last days of
+// the server, today included, oldest first. Days without counts, e.g. when
+// they expired, are zero.
+func (s *Server) StatsHistory(days int) ([]DayStats, error) {
+	return s.statsHistory(context.Background(), days)
+}
+
+func (s *Server) statsHistory(ctx context.Context, days int) ([]DayStats, error) {
+	if days < 1 || days > maxStatsHistoryDays {
+		return nil, fmt.Errorf("days must be between 1 and %d, got %d", maxStatsHistoryDays, days)
+	}
+
+	history := make([]DayStats, days)
+	processedKeys := make([]string, days)
+	failedKeys := make([]string, days)
+
+	today := time.Now().UTC()
+	for i := range history {
+		date := today.AddDate(0, 0, i-days+1).Format(STATS_DAY_LAYOUT)
+		history[i].Date = date
+		processedKeys[i] = s.config.Namespace + "stat:processed:" + date
+		failedKeys[i] = s.config.Namespace + "stat:failed:" + date
+	}
+
+	pipe := s.config.Client.Pipeline()
+	processed := pipe.MGet(ctx, processedKeys...)
+	failed := pipe.MGet(ctx, failedKeys...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for i := range history {
+		history[i].Processed = parseCount(processed.Val()[i])
+		history[i].Failed = parseCount(failed.Val()[i])
+	}
+
+	return history, nil
+}
+
+// parseCount parses a counter read with MGET, nil when the key is missing
+func parseCount(value interface{}) int64 {
+	s, _ := value.(string)
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// StatsHistoryHandler writes the stats history of the default server on
+// response writer
+func StatsHistoryHandler(w http.ResponseWriter, req *http.Request) {
+	defaultServer.StatsHistoryHandler(w, req)
+}
+
+// StatsHistoryHandler writes the processed and failed counts of the last
+// days given by the days parameter, 30 by default and 365 at most, on
+// response writer
+func (s *Server) StatsHistoryHandler(w http.ResponseWriter, req *http.Request) {
+	days := defaultStatsHistoryDays
+	if param := req.URL.Query().Get("days"); param != "" {
+		var err error
+		if days, err = strconv.Atoi(param); err != nil || days < 1 || days > maxStatsHistoryDays {
+			http.Error(w, fmt.Sprintf("days must be an integer between 1 and %d", maxStatsHistoryDays), http.StatusBadRequest)
+			return
+		}
+	}
+
+	history, err := s.statsHistory(req.Context(), days)
+	if err != nil {
+		Logger.Errorln("failed to retrieve stats history:", err)
+		http.Error(w, "failed to retrieve stats history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	body, _ := json.MarshalIndent(history, "", "  ")
+	fmt.Fprintln(w, string(body))
+}
+
+// QueueLatency returns how long the next job of a queue has been waiting,
+// zero when the queue is empty.
+func QueueLatency(queue string) (time.Duration, error) {
+	return defaultServer.QueueLatency(queue)
+}
+
+// QueueLatency returns how long the next job of a queue has been waiting
+func (s *Server) QueueLatency(queue string) (time.Duration, error) {
+	return s.queueLatency(context.Background(), s.config.Namespace+"queue:"+queue)
+}
+
+// queueLatency returns how long the next message of a queue has been
+// waiting, from the enqueued_at of the tail element fetched next.
+func (s *Server) queueLatency(ctx context.Context, queue string) (time.Duration, error) {
+	return headLatency(s.config.Client.LIndex(ctx, queue, -1))
+}
+
+// headLatency returns how long the message read by an LIndex of the head of
+// a queue has been waiting, zero when the queue is empty.
+func headLatency(head *redis.StringCmd) (time.Duration, error) {
+	message, err := head.Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	msg, err := NewMsg(message)
+	if err != nil {
+		return 0, err
+	}
+
+	enqueuedAt, err := msg.Get("enqueued_at").Float64()
+	if err != nil || enqueuedAt == 0 {
+		return 0, nil
+	}
+
+	latency := nowToSecondsWithNanoPrecision() - enqueuedAt
+	if latency < 0 {
+		return 0, nil
+	}
+
+	return time.Duration(latency * NanoSecondPrecision), nil
+}
@@ -0,0 +1,142 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func StatsSpec(c gospec.Context) {
+	const queueName = "queue-stats"
+
+	ctx := context.Background()
+
+	Process(queueName, myJob, 1)
+
+	enqueueSince := func(ago time.Duration) {
+		payload := fmt.Sprintf("{\"jid\":\"1\",\"args\":[],\"enqueued_at\":%f}", nowToSecondsWithNanoPrecision()-ago.Seconds())
+		Config.Client.LPush(ctx, "queue:"+queueName, payload)
+	}
+
+	c.Specify("QueueLatency", func() {
+		c.Specify("is zero for empty queues", func() {
+			latency, err := QueueLatency(queueName)
+			c.Expect(err, IsNil)
+			c.Expect(latency, Equals, time.Duration(0))
+		})
+
+		c.Specify("is the wait of the job fetched next", func() {
+			enqueueSince(time.Minute)
+			enqueueSince(time.Second)
+
+			latency, err := QueueLatency(queueName)
+			c.Expect(err, IsNil)
+			c.Expect(latency >= time.Minute, IsTrue)
+			c.Expect(latency < time.Minute+5*time.Second, IsTrue)
+		})
+	})
+
+	c.Specify("reports the latency of queues", func() {
+		enqueueSince(time.Minute)
+
+		latency := GetStats().Latency[queueName]
+		c.Expect(latency >= 60, IsTrue)
+		c.Expect(latency < 65, IsTrue)
+
+		w := httptest.NewRecorder()
+		Stats(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+		var body struct {
+			Latency map[string]float64 `json:"latency"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		c.Expect(body.Latency[queueName] >= 60, IsTrue)
+	})
+
+	c.Specify("reports zero latency for empty queues next to others", func() {
+		Process(queueName+"-empty", myJob, 1)
+		enqueueSince(time.Minute)
+
+		stats := GetStats()
+		c.Expect(stats.Latency[queueName] >= 60, IsTrue)
+
+		latency, ok := stats.Latency[queueName+"-empty"]
+		c.Expect(ok, IsTrue)
+		c.Expect(latency, Equals, float64(0))
+	})
+
+	c.Specify("StatsHistory", func() {
+		day := func(ago int) string {
+			return time.Now().UTC().AddDate(0, 0, -ago).Format(STATS_DAY_LAYOUT)
+		}
+
+		Config.Client.Set(ctx, "stat:processed:"+day(0), 5, 0)
+		Config.Client.Set(ctx, "stat:failed:"+day(0), 1, 0)
+		Config.Client.Set(ctx, "stat:processed:"+day(2), 3, 0)
+		Config.Client.Set(ctx, "stat:processed:"+day(3), 7, 0)
+
+		c.Specify("returns the counts of the last days, oldest first", func() {
+			history, err := StatsHistory(3)
+			c.Expect(err, IsNil)
+			c.Expect(len(history), Equals, 3)
+			c.Expect(history[0], Equals, DayStats{day(2), 3, 0})
+			c.Expect(history[1], Equals, DayStats{day(1), 0, 0})
+			c.Expect(history[2], Equals, DayStats{day(0), 5, 1})
+		})
+
+		c.Specify("requires a positive number of days", func() {
+			_, err := StatsHistory(0)
+			c.Expect(err, Not(IsNil))
+		})
+
+		c.Specify("returns up to a year", func() {
+			history, err := StatsHistory(365)
+			c.Expect(err, IsNil)
+			c.Expect(len(history), Equals, 365)
+
+			_, err = StatsHistory(366)
+			c.Expect(err, Not(IsNil))
+		})
+
+		c.Specify("is served under /stats/history", func() {
+			w := httptest.NewRecorder()
+			StatsHistoryHandler(w, httptest.NewRequest(http.MethodGet, "/stats/history?days=2", nil))
+
+			var history []DayStats
+			json.Unmarshal(w.Body.Bytes(), &history)
+			c.Expect(w.Code, Equals, http.StatusOK)
+			c.Expect(len(history), Equals, 2)
+			c.Expect(history[1], Equals, DayStats{day(0), 5, 1})
+		})
+
+		c.Specify("serves 30 days by default", func() {
+			w := httptest.NewRecorder()
+			StatsHistoryHandler(w, httptest.NewRequest(http.MethodGet, "/stats/history", nil))
+
+			var history []DayStats
+			json.Unmarshal(w.Body.Bytes(), &history)
+			c.Expect(len(history), Equals, 30)
+			c.Expect(history[26], Equals, DayStats{day(3), 7, 0})
+		})
+
+		c.Specify("rejects invalid days", func() {
+			w := httptest.NewRecorder()
+			StatsHistoryHandler(w, httptest.NewRequest(http.MethodGet, "/stats/history?days=abc", nil))
+
+			c.Expect(w.Code, Equals, http.StatusBadRequest)
+		})
+
+		c.Specify("rejects more than a year", func() {
+			w := httptest.NewRecorder()
+			StatsHistoryHandler(w, httptest.NewRequest(http.MethodGet, "/stats/history?days=366", nil))
+
+			c.Expect(w.Code, Equals, http.StatusBadRequest)
+		})
+	})
+}
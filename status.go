@@ -0,0 +1,178 @@
+package workers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// States of a job tracked with EnqueueOptions{TrackStatus: true}
+const (
+	STATUS_QUEUED    = "queued"
+	STATUS_SCHEDULED = "scheduled"
+	STATUS_WORKING   = "working"
+	STATUS_RETRYING  = "retrying"
+	STATUS_COMPLETE  = "complete"
+	STATUS_FAILED    = "failed"
+	STATUS_DEAD      = "dead"
+	STATUS_EXPIRED   = "expired"
+	STATUS_DISCARDED = "discarded"
+
+	DEFAULT_STATUS_EXPIRATION = 24 * time.Hour
+)
+
+// Status is the record of a tracked job. At holds when the job last entered
+// each of the states it went through, in seconds since the epoch.
+type Status struct {
+	Jid       string             `json:"jid"`
+	State     string             `json:"state"`
+	Queue     string             `json:"queue"`
+	Class     string             `json:"class"`
+	Progress  int                `json:"progress"`
+	Message   string             `json:"message"`
+	Error     string             `json:"error"`
+	At        map[string]float64 `json:"at"`
+	UpdatedAt float64            `json:"updated_at"`
+}
+
+// JobStatus returns the status of a tracked job, or nil when the job isn't
+// tracked or its status expired.
+func JobStatus(jid string) (*Status, error) {
+	return defaultClient.JobStatus(jid)
+}
+
+// JobStatus returns the status of a tracked job
+func (c *Client) JobStatus(jid string) (*Status, error) {
+	fields, err := c.config.Client.HGetAll(context.Background(), statusKey(c.config, jid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	status := &Status{
+		Jid:     jid,
+		State:   fields["state"],
+		Queue:   fields["queue"],
+		Class:   fields["class"],
+		Message: fields["message"],
+		Error:   fields["error"],
+		At:      make(map[string]float64),
+	}
+	status.Progress, _ = strconv.Atoi(fields["progress"])
+	status.UpdatedAt, _ = strconv.ParseFloat(fields["updated_at"], 64)
+
+	for field, value := range fields {
+		if state := strings.TrimSuffix(field, "_at"); state != field && state != "updated" {
+			status.At[state], _ = strconv.ParseFloat(value, 64)
+		}
+	}
+
+	return status, nil
+}
+
+// SetProgress records how far the job got, in percent, along with a
+// message, in its status. It does nothing for jobs whose status isn't
+// tracked.
+func (m *Msg) SetProgress(pct int, message string) error {
+	if !m.tracked() {
+		return nil
+	}
+
+	config := serverOf(m).config
+	return setStatus(m.Context(), config, m, "", "progress", pct, "message", message)
+}
+
+// trackEnqueued records the state of a tracked job being enqueued in the
+// transaction pushing it, so that it can't overwrite the state of a worker
+// which already fetched it.
+func (c *Client) trackEnqueued(ctx context.Context, pipe redis.Pipeliner, bytes []byte, state string) {
+	message, err := NewMsg(string(bytes))
+	if err != nil {
+		return
+	}
+
+	queueStatus(ctx, pipe, c.config, message, state)
+}
+
+// tracked reports whether the status of the job is recorded
+func (m *Msg) tracked() bool {
+	tracked, _ := m.Get("track_status").Bool()
+	return tracked
+}
+
+// trackStatus records the state a tracked job enters on an event. Failed
+// jobs are only recorded as failed when they weren't retried or killed.
+func (s *Server) trackStatus(event jobEvent, message *Msg, err error) {
+	if !message.tracked() {
+		return
+	}
+
+	var fields []interface{}
+	if err != nil {
+		fields = append(fields, "error", err.Error())
+	}
+
+	var state string
+	switch event {
+	case jobStarted:
+		state = STATUS_WORKING
+	case jobSucceeded:
+		state = STATUS_COMPLETE
+		fields = append(fields, "progress", 100)
+	case jobRetried:
+		state = STATUS_RETRYING
+	case jobDied:
+		state = STATUS_DEAD
+	case jobExpired:
+		state = STATUS_EXPIRED
+	case jobDiscarded:
+		state = STATUS_DISCARDED
+	case jobFailed:
+		if message.status != STATUS_WORKING {
+			return
+		}
+		state = STATUS_FAILED
+	default:
+		return
+	}
+
+	if err := setStatus(context.Background(), s.config, message, state, fields...); err != nil {
+		Logger.Errorln("failed to track status of", message.Jid(), ":", err)
+	}
+}
+
+// setStatus updates the status of a message, moving it to state unless
+// empty, and extends its expiration.
+func setStatus(ctx context.Context, config *WorkerConfig, message *Msg, state string, fields ...interface{}) error {
+	pipe := config.Client.TxPipeline()
+	queueStatus(ctx, pipe, config, message, state, fields...)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// queueStatus queues the commands of setStatus in pipe
+func queueStatus(ctx context.Context, pipe redis.Pipeliner, config *WorkerConfig, message *Msg, state string, fields ...interface{}) {
+	now := nowToSecondsWithNanoPrecision()
+	queue, _ := message.Get("queue").String()
+	class, _ := message.Get("class").String()
+
+	fields = append(fields, "queue", queue, "class", class, "updated_at", now)
+	if state != "" {
+		fields = append(fields, "state", state, state+"_at", now)
+		message.status = state
+	}
+
+	key := statusKey(config, message.Jid())
+	pipe.HSet(ctx, key, fields...)
+	pipe.Expire(ctx, key, config.StatusExpiration)
+}
+
+func statusKey(config *WorkerConfig, jid string) string {
+	return config.Namespace + STATUS_KEY + ":" + jid
+}
@@ -0,0 +1,201 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/customerio/gospec"
+	. "github.com/customerio/gospec"
+)
+
+func StatusSpec(c gospec.Context) {
+	const queueName = "queue-status"
+
+	ctx := context.Background()
+	conn := Config.Client
+
+	enqueue := func(opts EnqueueOptions) string {
+		opts.TrackStatus = true
+		jid, _ := EnqueueWithOptions(queueName, "Export", []int{1}, opts)
+		return jid
+	}
+
+	process := func(job jobFunc) *Msg {
+		payload, _ := conn.RPop(ctx, "queue:"+queueName).Result()
+		message, _ := NewMsg(payload)
+
+		worker := newWorker(defaultServer.newManager(queueName, job, 1))
+		worker.process(message)
+		return message
+	}
+
+	c.Specify("JobStatus", func() {
+		c.Specify("records queued jobs", func() {
+			jid := enqueue(EnqueueOptions{})
+
+			status, err := JobStatus(jid)
+			c.Expect(err, IsNil)
+			c.Expect(status.State, Equals, STATUS_QUEUED)
+			c.Expect(status.Queue, Equals, queueName)
+			c.Expect(status.Class, Equals, "Export")
+			c.Expect(status.At[STATUS_QUEUED] > 0, IsTrue)
+
+			ttl, _ := conn.TTL(ctx, "status:"+jid).Result()
+			c.Expect(ttl > 23*time.Hour, IsTrue)
+		})
+
+		c.Specify("records scheduled jobs", func() {
+			jid := enqueue(EnqueueOptions{At: nowToSecondsWithNanoPrecision() + 60})
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_SCHEDULED)
+		})
+
+		c.Specify("records partitioned jobs", func() {
+			jid := enqueue(EnqueueOptions{Partition: "account-1"})
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_QUEUED)
+		})
+
+		c.Specify("returns nil for untracked jobs", func() {
+			jid, _ := Enqueue(queueName, "Export", []int{1})
+
+			status, err := JobStatus(jid)
+			c.Expect(err, IsNil)
+			c.Expect(status, IsNil)
+		})
+	})
+
+	c.Specify("tracks jobs as they run", func() {
+		c.Specify("records progress and completion", func() {
+			jid := enqueue(EnqueueOptions{})
+
+			var working *Status
+			process(func(message *Msg) {
+				message.SetProgress(50, "halfway")
+				working, _ = JobStatus(message.Jid())
+			})
+
+			c.Expect(working.State, Equals, STATUS_WORKING)
+			c.Expect(working.Progress, Equals, 50)
+			c.Expect(working.Message, Equals, "halfway")
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_COMPLETE)
+			c.Expect(status.Progress, Equals, 100)
+			c.Expect(status.At[STATUS_WORKING] > 0, IsTrue)
+			c.Expect(status.At[STATUS_COMPLETE] >= status.At[STATUS_WORKING], IsTrue)
+		})
+
+		c.Specify("records retried jobs", func() {
+			jid := enqueue(EnqueueOptions{Retry: true})
+			process(func(message *Msg) {
+				panic("AHHHH")
+			})
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_RETRYING)
+			c.Expect(status.Error, Equals, "AHHHH")
+		})
+
+		c.Specify("records dead jobs", func() {
+			jid := enqueue(EnqueueOptions{Retry: true, RetryMax: 1, RetryCount: 1})
+			process(func(message *Msg) {
+				panic("AHHHH")
+			})
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_DEAD)
+		})
+
+		c.Specify("records failed jobs which aren't retried", func() {
+			jid := enqueue(EnqueueOptions{})
+			process(func(message *Msg) {
+				panic("AHHHH")
+			})
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_FAILED)
+			c.Expect(status.Error, Equals, "AHHHH")
+		})
+
+		c.Specify("records expired jobs", func() {
+			jid := enqueue(EnqueueOptions{ExpiresAt: 1})
+			process(func(message *Msg) {})
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_EXPIRED)
+		})
+
+		c.Specify("records discarded jobs", func() {
+			jid := enqueue(EnqueueOptions{Retry: true})
+			process(func(message *Msg) {
+				panic(Discard(errors.New("no longer relevant")))
+			})
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_DISCARDED)
+			c.Expect(status.Error, Equals, "no longer relevant")
+		})
+
+		c.Specify("doesn't record progress of untracked jobs", func() {
+			jid, _ := Enqueue(queueName, "Export", []int{1})
+			process(func(message *Msg) {
+				c.Expect(message.SetProgress(50, "halfway"), IsNil)
+			})
+
+			exists, _ := conn.Exists(ctx, "status:"+jid).Result()
+			c.Expect(exists, Equals, int64(0))
+		})
+
+		c.Specify("records jobs queued again for a retry", func() {
+			jid := enqueue(EnqueueOptions{Retry: true})
+			process(func(message *Msg) {
+				panic("AHHHH")
+			})
+
+			NewRetrySet().RetryAll(ctx)
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_QUEUED)
+			c.Expect(status.At[STATUS_RETRYING] > 0, IsTrue)
+		})
+
+		c.Specify("records jobs rescheduled by a rate limit", func() {
+			SetQueueRateLimit(queueName, SlidingWindow{Limit: 1, Window: time.Hour})
+
+			enqueue(EnqueueOptions{})
+			jid := enqueue(EnqueueOptions{})
+			process(func(message *Msg) {})
+			process(func(message *Msg) {})
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_SCHEDULED)
+
+			SetQueueRateLimit(queueName, nil)
+		})
+
+		c.Specify("records jobs requeued on shutdown", func() {
+			jid := enqueue(EnqueueOptions{})
+
+			payload, _ := conn.RPop(ctx, "queue:"+queueName).Result()
+			message, _ := NewMsg(payload)
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			message.ctx = cancelled
+
+			manager := defaultServer.newManager(queueName, func(message *Msg) {
+				panic(message.Context().Err())
+			}, 1)
+			newWorker(manager).process(message)
+			c.Expect(message.interrupted, IsTrue)
+			manager.requeue(message)
+
+			status, _ := JobStatus(jid)
+			c.Expect(status.State, Equals, STATUS_QUEUED)
+			c.Expect(status.At[STATUS_WORKING] > 0, IsTrue)
+		})
+	})
+}
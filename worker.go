@@ -1,6 +1,7 @@
 package workers
 
 import (
+	"context"
 	"sync/atomic"
 	"time"
 )
@@ -24,12 +25,31 @@ func (w *worker) quit() {
 
 func (w *worker) work(messages chan *Msg) {
 	for {
+		// While paused, stop signaling the fetcher until resumed. Once
+		// the shutdown timeout expired, stop signaling it for good, so
+		// that jobs pushed back to their queue aren't fetched again.
+		ready := w.manager.fetch.Ready()
+		resumed := w.manager.resumed()
+		if resumed != nil || w.manager.abandoning() {
+			ready = nil
+		}
+
 		select {
 		case message := <-messages:
 			atomic.StoreInt64(&w.startedAt, time.Now().UTC().Unix())
+			message.ctx = w.manager.ctx
+			message.server = w.manager.server
 			w.currentMsg = message
 
-			if w.process(message) {
+			acknowledge := w.process(message)
+
+			switch {
+			case message.abandoned():
+				// Already pushed back at the shutdown deadline
+			case message.interrupted:
+				// Interrupted by the shutdown timeout, it runs again
+				w.manager.requeue(message)
+			case acknowledge:
 				w.manager.confirm <- message
 			}
 
@@ -44,9 +64,10 @@ func (w *worker) work(messages chan *Msg) {
 			case w.manager.fetch.FinishedWork() <- true:
 			default:
 			}
-		case w.manager.fetch.Ready() <- true:
+		case ready <- true:
 			// Signaled to fetcher that we're
 			// ready to accept a message
+		case <-resumed:
 		case <-w.stop:
 			w.exit <- true
 			return
@@ -55,15 +76,84 @@ func (w *worker) work(messages chan *Msg) {
 }
 
 func (w *worker) process(message *Msg) (acknowledge bool) {
+	server := w.manager.server
+	queue := w.manager.queueName()
+
+	// Let the next job of its partition run once the job is done for good,
+	// unless it was interrupted or pushed back at the shutdown deadline, and
+	// runs again. The job is acknowledged even when that fails, as it
+	// already ran.
+	defer func() {
+		if message.release() && acknowledge && !message.deferred && !message.interrupted {
+			completePartition(context.Background(), server.config, queue, message)
+		}
+	}()
+
+	if message.expired() {
+		server.runJobHooks(jobExpired, queue, message, nil, 0)
+		return true
+	}
+
+	release, ok := server.limit(queue, message)
+	if !ok {
+		return true
+	}
+	defer release()
+
+	message.startedAt = time.Now()
+	server.runJobHooks(jobStarted, queue, message, nil, 0)
+
+	var err error
+	acknowledge, err = w.run(queue, message)
+	if err != nil {
+		// A job failing once the shutdown timeout cancelled it was
+		// interrupted, and runs again unless it was retried or killed.
+		// Jobs which succeed or are discarded meanwhile are done.
+		if message.Context().Err() != nil && !message.deferred && !message.dead {
+			message.interrupted = true
+			return
+		}
+
+		server.runJobHooks(jobFailed, queue, message, err, message.elapsed())
+		return
+	}
+
+	if message.discarded != nil {
+		server.runJobHooks(jobDiscarded, queue, message, message.discarded, message.elapsed())
+		return
+	}
+
+	server.runJobHooks(jobSucceeded, queue, message, nil, message.elapsed())
+
+	return
+}
+
+// run calls the job through its middlewares, and returns the error of its
+// panic if it failed.
+func (w *worker) run(queue string, message *Msg) (acknowledge bool, err error) {
 	acknowledge = true
 
 	defer func() {
-		recover()
+		if e := recover(); e != nil {
+			err = panicToError(e)
+		}
 	}()
 
-	return w.manager.mids.call(w.manager.queueName(), message, func() {
+	acknowledge = w.manager.mids.call(queue, message, func() {
 		w.manager.job(message)
 	})
+
+	return
+}
+
+// current returns the message being processed and when it started, or nil
+// when idle.
+func (w *worker) current() (*Msg, int64) {
+	startedAt := atomic.LoadInt64(&w.startedAt)
+	if startedAt == 0 {
+		return nil, 0
+	}
+	return w.currentMsg, startedAt
 }
 
 func (w *worker) processing() bool {
@@ -45,7 +45,7 @@ func WorkerSpec(c gospec.Context) {
 		processed <- message.Args()
 	})
 
-	manager := newManager(queueName, testJob, 1)
+	manager := defaultServer.newManager(queueName, testJob, 1)
 
 	c.Specify("newWorker", func() {
 		c.Specify("it returns an instance of worker with connection to manager", func() {
@@ -127,7 +127,7 @@ func WorkerSpec(c gospec.Context) {
 				panic("AHHHHHHHHH")
 			})
 
-			manager := newManager(queueName, panicJob, 1)
+			manager := defaultServer.newManager(queueName, panicJob, 1)
 			worker := newWorker(manager)
 
 			go worker.work(messages)
@@ -138,4 +138,64 @@ func WorkerSpec(c gospec.Context) {
 			worker.quit()
 		})
 	})
+
+	c.Specify("process", func() {
+		events := []string{}
+		record := func(event string) JobHook {
+			return func(queue string, message *Msg, err error, duration time.Duration) {
+				events = append(events, event+":"+queue)
+				if err != nil {
+					events = append(events, err.Error())
+				}
+			}
+		}
+		OnStart(record("start"))
+		OnSuccess(record("success"))
+		OnFailure(record("failure"))
+		OnExpire(record("expire"))
+
+		ran := false
+		manager := defaultServer.newManager(queueName, func(message *Msg) {
+			ran = true
+		}, 1)
+		worker := newWorker(manager)
+
+		c.Specify("runs hooks around successful jobs", func() {
+			message, _ := NewMsg("{\"jid\":\"2\",\"args\":[]}")
+
+			c.Expect(worker.process(message), IsTrue)
+			c.Expect(events, ContainsExactly, Values("start:"+queueName, "success:"+queueName))
+		})
+
+		c.Specify("runs hooks around failed jobs", func() {
+			manager := defaultServer.newManager(queueName, func(message *Msg) {
+				panic("AHHHH")
+			}, 1)
+			worker := newWorker(manager)
+			message, _ := NewMsg("{\"jid\":\"2\",\"args\":[]}")
+
+			worker.process(message)
+			c.Expect(events, ContainsExactly, Values("start:"+queueName, "failure:"+queueName, "AHHHH"))
+		})
+
+		c.Specify("doesn't fail jobs whose success hooks panic", func() {
+			OnSuccess(func(queue string, message *Msg, err error, duration time.Duration) {
+				panic("AHHHH")
+			})
+			message, _ := NewMsg("{\"jid\":\"2\",\"args\":[]}")
+
+			c.Expect(worker.process(message), IsTrue)
+			c.Expect(events, ContainsExactly, Values("start:"+queueName, "success:"+queueName))
+		})
+
+		c.Specify("skips expired jobs", func() {
+			message, _ := NewMsg("{\"jid\":\"2\",\"args\":[],\"expires_at\":1}")
+
+			c.Expect(worker.process(message), IsTrue)
+			c.Expect(ran, IsFalse)
+			c.Expect(events, ContainsExactly, Values("expire:"+queueName))
+		})
+
+		defaultServer.jobHooks = make(map[jobEvent][]JobHook)
+	})
 }
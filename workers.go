@@ -2,126 +2,85 @@ package workers
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"net/http"
-	"sync"
 )
 
 const (
 	RETRY_KEY          = "goretry"
 	SCHEDULED_JOBS_KEY = "schedule"
+	DEAD_KEY           = "dead"
+	PAUSED_KEY         = "paused"
+	CONCURRENCY_KEY    = "concurrency"
+	CONTROL_CHANNEL    = "control"
+	RATE_LIMIT_KEY     = "limit"
+	PARTITION_KEY      = "partition"
+	STATUS_KEY         = "status"
+	RESULT_KEY         = "result"
 )
 
-var managers = make(map[string]*manager)
-var schedule *scheduled
-var control = make(map[string]chan string)
-var access sync.Mutex
-var started bool
+// defaultServer backs the package-level functions. Its configuration is set
+// by Configure and its jobs use the package Middleware.
+var defaultServer = newServer(nil, nil)
 
-var Middleware = NewMiddleware(
-	&MiddlewareLogging{},
-	&MiddlewareRetry{},
-	&MiddlewareStats{},
-)
-
-func Process(queue string, job jobFunc, concurrency int, mids ...Action) {
-	access.Lock()
-	defer access.Unlock()
+var Middleware = defaultMiddleware()
 
-	managers[queue] = newManager(queue, job, concurrency, mids...)
+func defaultMiddleware() *Middlewares {
+	return NewMiddleware(
+		&MiddlewareLogging{},
+		&MiddlewareRetry{},
+		&MiddlewareStats{},
+	)
 }
 
-func Run() {
-	Start()
-	go handleSignals()
-	waitForExit()
-}
-
-func ResetManagers() error {
-	access.Lock()
-	defer access.Unlock()
-
-	if started {
-		return errors.New("Cannot reset worker managers while workers are running")
-	}
-
-	managers = make(map[string]*manager)
-
-	return nil
+// Process registers a job function for a queue. Once workers are started,
+// the queue starts being processed right away, after draining the previous
+// manager of the queue if any.
+func Process(queue string, job jobFunc, concurrency int, mids ...Action) {
+	defaultServer.Process(queue, job, concurrency, mids...)
 }
 
-func Start() {
-	ctx := context.Background()
-	access.Lock()
-	defer access.Unlock()
-
-	if started {
-		return
-	}
-
-	runHooks(beforeStart)
-	startSchedule(ctx)
-	startManagers()
-
-	started = true
+// StopProcessing stops fetching messages from a queue, waits for its
+// in-flight jobs to finish and removes it, without affecting other queues.
+func StopProcessing(queue string) error {
+	return defaultServer.StopProcessing(queue)
 }
 
-func Quit() {
-	access.Lock()
-	defer access.Unlock()
-
-	if !started {
-		return
-	}
-
-	quitManagers()
-	quitSchedule()
-	runHooks(duringDrain)
-	waitForExit()
-
-	started = false
+func Run() {
+	defaultServer.Run()
 }
 
-func StatsServer(port int) {
-	http.HandleFunc("/stats", Stats)
-
-	Logger.Infoln("Stats are available at", fmt.Sprint("http://localhost:", port, "/stats"))
-
-	if err := http.ListenAndServe(fmt.Sprint(":", port), nil); err != nil {
-		Logger.Error("failed to start stats server", err)
-	}
+// RunContext starts workers and blocks until ctx is cancelled or Quit is
+// called, draining in-flight jobs before returning. Unlike Run, it leaves
+// signal handling to the caller. It returns a *ShutdownError when the
+// shutdown timeout expired with jobs still running.
+func RunContext(ctx context.Context) error {
+	return defaultServer.RunContext(ctx)
 }
 
-func startSchedule(ctx context.Context) {
-	if schedule == nil {
-		schedule = newScheduled(RETRY_KEY, SCHEDULED_JOBS_KEY)
-	}
-
-	schedule.start(ctx)
+func ResetManagers() error {
+	return defaultServer.ResetManagers()
 }
 
-func quitSchedule() {
-	if schedule != nil {
-		schedule.quit()
-		schedule = nil
-	}
+func Start() {
+	defaultServer.Start()
 }
 
-func startManagers() {
-	for _, manager := range managers {
-		manager.start()
-	}
+// Quiet stops fetching new messages and polling scheduled jobs, and lets
+// in-flight jobs finish. The process keeps running, along with its stats
+// server and control channel, until Quit is called.
+func Quiet() {
+	defaultServer.Quiet()
 }
 
-func quitManagers() {
-	for _, m := range managers {
-		go (func(m *manager) { m.quit() })(m)
-	}
+// Quit stops fetching messages and waits for in-flight jobs to finish. When
+// Config.ShutdownTimeout expires first, the context of jobs still running is
+// cancelled, the jobs which then fail are pushed back to the front of their
+// queue and Quit returns their JIDs. So are the jobs ignoring the
+// cancellation, whose outcome no longer counts once they return.
+func Quit() []string {
+	return defaultServer.Quit()
 }
 
-func waitForExit() {
-	for _, manager := range managers {
-		manager.Wait()
-	}
+func StatsServer(port int) {
+	defaultServer.serveStats(http.DefaultServeMux, port)
 }
@@ -1,10 +1,13 @@
 package workers
 
 import (
+	"context"
 	"reflect"
+	"time"
 
 	"github.com/customerio/gospec"
 	. "github.com/customerio/gospec"
+	"github.com/redis/go-redis/v9"
 )
 
 var called chan bool
@@ -33,6 +36,280 @@ func WorkersSpec(c gospec.Context) {
 			Quit()
 		})
 
+		c.Specify("processes queues added while running", func() {
+			called = make(chan bool)
+
+			Start()
+
+			Process(queueName, myJob, 10)
+
+			_, err := Enqueue(queueName, "Add", []int{1, 2})
+			if err != nil {
+				panic(err)
+			}
+			<-called
+
+			Quit()
+		})
+
+		c.Specify("drains replaced queues without blocking other calls", func() {
+			running := make(chan bool)
+			release := make(chan bool)
+			noop := func(message *Msg) {}
+
+			Process(queueName, func(message *Msg) {
+				running <- true
+				<-release
+			}, 1)
+
+			Start()
+
+			Enqueue(queueName, "Add", []int{1, 2})
+			<-running
+
+			replaced := make(chan bool)
+			go (func() {
+				Process(queueName, noop, 1)
+				close(replaced)
+			})()
+			time.Sleep(50 * time.Millisecond)
+
+			added := make(chan bool)
+			go (func() {
+				Process("queue-workers2", noop, 1)
+				close(added)
+			})()
+
+			select {
+			case <-added:
+			case <-time.After(time.Second):
+				c.Expect("Process", Equals, "not blocked by the drain")
+			}
+
+			close(release)
+			<-replaced
+
+			Quit()
+		})
+
+		c.Specify("stops processing a single queue", func() {
+			called = make(chan bool)
+
+			Process(queueName, myJob, 10)
+			Process("queue-workers2", myJob, 10)
+
+			Start()
+
+			err := StopProcessing("queue-workers2")
+			c.Expect(err, IsNil)
+			c.Expect(len(defaultServer.managers), Equals, 1)
+
+			// Enqueue through a connection of its own, as the pops
+			// pending on both queues may hold the one of the workers
+			pusher := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+			defer pusher.Close()
+			client := NewClient(Options{RedisClient: pusher})
+
+			client.Enqueue("queue-workers2", "Add", []int{1, 2})
+			client.Enqueue(queueName, "Add", []int{1, 2})
+			<-called
+
+			Quit()
+
+			conn := Config.Client
+			count, _ := conn.LLen(context.Background(), "queue:queue-workers2").Result()
+			c.Expect(count, Equals, int64(1))
+		})
+
+		c.Specify("stops fetching while quiet", func() {
+			called = make(chan bool)
+
+			Process(queueName, myJob, 10)
+
+			Start()
+			Quiet()
+
+			c.Expect(defaultServer.started, IsTrue)
+			c.Expect(defaultServer.managers[queueName].fetch.Closed(), IsTrue)
+
+			Enqueue(queueName, "Add", []int{1, 2})
+			time.Sleep(100 * time.Millisecond)
+
+			Quit()
+
+			conn := Config.Client
+			count, _ := conn.LLen(context.Background(), "queue:"+queueName).Result()
+			c.Expect(count, Equals, int64(1))
+		})
+
+		c.Specify("requeues jobs cancelled by the shutdown timeout", func() {
+			running := make(chan bool)
+
+			Process(queueName, func(message *Msg) {
+				running <- true
+				<-message.Context().Done()
+				panic(message.Context().Err())
+			}, 1)
+			Config.ShutdownTimeout = 200 * time.Millisecond
+
+			Start()
+
+			jid, _ := Enqueue(queueName, "Add", []int{1, 2})
+			<-running
+
+			requeued := Quit()
+			c.Expect(requeued, ContainsExactly, Values(jid))
+
+			conn := Config.Client
+			count, _ := conn.LLen(context.Background(), "queue:"+queueName).Result()
+			inprogress, _ := conn.LLen(context.Background(), "queue:"+queueName+":1:inprogress").Result()
+			c.Expect(count, Equals, int64(1))
+			c.Expect(inprogress, Equals, int64(0))
+
+			ResetManagers()
+		})
+
+		c.Specify("doesn't requeue jobs done once cancelled by the shutdown timeout", func() {
+			running := make(chan bool)
+
+			Process(queueName, func(message *Msg) {
+				running <- true
+				<-message.Context().Done()
+				if message.Args().MustArray()[0] == "fail" {
+					panic("AHHHH")
+				}
+			}, 2)
+			Config.ShutdownTimeout = 200 * time.Millisecond
+
+			Start()
+
+			Enqueue(queueName, "Add", []string{"succeed"})
+			EnqueueWithOptions(queueName, "Add", []string{"fail"}, EnqueueOptions{Retry: true, RetryMax: 1, RetryCount: 1})
+			<-running
+			<-running
+
+			requeued := Quit()
+			c.Expect(len(requeued), Equals, 0)
+
+			conn := Config.Client
+			count, _ := conn.LLen(context.Background(), "queue:"+queueName).Result()
+			inprogress, _ := conn.LLen(context.Background(), "queue:"+queueName+":1:inprogress").Result()
+			dead, _ := conn.ZCard(context.Background(), DEAD_KEY).Result()
+			c.Expect(count, Equals, int64(0))
+			c.Expect(inprogress, Equals, int64(0))
+			c.Expect(dead, Equals, int64(1))
+
+			ResetManagers()
+		})
+
+		c.Specify("pushes back jobs still running after the shutdown timeout", func() {
+			running := make(chan bool)
+			release := make(chan bool)
+
+			Process(queueName, func(message *Msg) {
+				running <- true
+				<-release
+				if err := message.Context().Err(); err != nil {
+					panic(err)
+				}
+			}, 1)
+			Config.ShutdownTimeout = 200 * time.Millisecond
+
+			Start()
+
+			jid, _ := Enqueue(queueName, "Add", []int{1, 2})
+			<-running
+
+			requeued := Quit()
+			c.Expect(requeued, ContainsExactly, Values(jid))
+			c.Expect(defaultServer.checkHealth(context.Background()).Draining, IsTrue)
+
+			conn := Config.Client
+			count, _ := conn.LLen(context.Background(), "queue:"+queueName).Result()
+			inprogress, _ := conn.LLen(context.Background(), "queue:"+queueName+":1:inprogress").Result()
+			c.Expect(count, Equals, int64(1))
+			c.Expect(inprogress, Equals, int64(0))
+
+			// Starting again doesn't wait for the job, which runs again
+			Start()
+			<-running
+
+			// Once it returns, the job isn't acknowledged nor retried
+			close(release)
+			Config.ShutdownTimeout = 0
+			requeued = Quit()
+			c.Expect(len(requeued), Equals, 0)
+
+			count, _ = conn.LLen(context.Background(), "queue:"+queueName).Result()
+			inprogress, _ = conn.LLen(context.Background(), "queue:"+queueName+":1:inprogress").Result()
+			retries, _ := conn.ZCard(context.Background(), RETRY_KEY).Result()
+			c.Expect(count, Equals, int64(0))
+			c.Expect(inprogress, Equals, int64(0))
+			c.Expect(retries, Equals, int64(0))
+
+			ResetManagers()
+		})
+
+		c.Specify("runs until the context is cancelled", func() {
+			called = make(chan bool)
+
+			Process(queueName, myJob, 10)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errs := make(chan error)
+			go (func() { errs <- RunContext(ctx) })()
+
+			Enqueue(queueName, "Add", []int{1, 2})
+			<-called
+
+			cancel()
+
+			c.Expect(<-errs, IsNil)
+			c.Expect(defaultServer.started, IsFalse)
+		})
+
+		c.Specify("reports jobs still running when the run context is cancelled", func() {
+			running := make(chan bool)
+			release := make(chan bool)
+
+			Process(queueName, func(message *Msg) {
+				running <- true
+				<-release
+				panic(message.Context().Err())
+			}, 1)
+			Config.ShutdownTimeout = 200 * time.Millisecond
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errs := make(chan error)
+			go (func() { errs <- RunContext(ctx) })()
+
+			jid, _ := Enqueue(queueName, "Add", []int{1, 2})
+			<-running
+
+			cancel()
+
+			err := <-errs
+			shutdownErr, ok := err.(*ShutdownError)
+			c.Expect(ok, IsTrue)
+			if ok {
+				c.Expect(shutdownErr.Requeued, ContainsExactly, Values(jid))
+				c.Expect(shutdownErr.Running, ContainsExactly, Values(jid))
+			}
+
+			conn := Config.Client
+			count, _ := conn.LLen(context.Background(), "queue:"+queueName).Result()
+			c.Expect(count, Equals, int64(1))
+
+			close(release)
+
+			ResetManagers()
+		})
+
+		c.Specify("fails to stop unknown queues", func() {
+			err := StopProcessing("unknown")
+			c.Expect(err, Not(IsNil))
+		})
+
 		// TODO make this test more deterministic, randomly locks up in travis.
 		//c.Specify("allows starting and stopping multiple times", func() {
 		//	called = make(chan bool)
@@ -70,7 +347,7 @@ func WorkersSpec(c gospec.Context) {
 			Quit()
 
 			// Clear out global hooks variable
-			beforeStart = nil
+			defaultServer.beforeStart = nil
 		})
 
 		c.Specify("runs beforeStart hooks", func() {
@@ -95,7 +372,61 @@ func WorkersSpec(c gospec.Context) {
 			c.Expect(reflect.DeepEqual(hooks, []string{"1", "2", "3"}), IsTrue)
 
 			// Clear out global hooks variable
-			duringDrain = nil
+			defaultServer.duringDrain = nil
+		})
+
+		c.Specify("runs start and quit hooks in order", func() {
+			hooks := []string{}
+
+			BeforeStart(func() {
+				hooks = append(hooks, "beforeStart")
+			})
+			AfterStart(func() {
+				hooks = append(hooks, "afterStart")
+			})
+			BeforeQuit(func() {
+				hooks = append(hooks, "beforeQuit")
+			})
+			DuringDrain(func() {
+				hooks = append(hooks, "duringDrain")
+			})
+			AfterQuit(func() {
+				hooks = append(hooks, "afterQuit")
+			})
+
+			Start()
+
+			c.Expect(reflect.DeepEqual(hooks, []string{"beforeStart", "afterStart"}), IsTrue)
+
+			Quit()
+
+			c.Expect(reflect.DeepEqual(hooks, []string{"beforeStart", "afterStart", "beforeQuit", "duringDrain", "afterQuit"}), IsTrue)
+
+			// Clear out global hooks variables
+			defaultServer.beforeStart = nil
+			defaultServer.afterStart = nil
+			defaultServer.beforeQuit = nil
+			defaultServer.duringDrain = nil
+			defaultServer.afterQuit = nil
+		})
+
+		c.Specify("runs start and quit hooks which call back into the server", func() {
+			Process(queueName, myJob, 1)
+			AfterStart(func() {
+				Process("queue-workers2", myJob, 1)
+			})
+			AfterQuit(func() {
+				StopProcessing("queue-workers2")
+			})
+
+			Start()
+			c.Expect(len(defaultServer.managers), Equals, 2)
+
+			Quit()
+			c.Expect(len(defaultServer.managers), Equals, 1)
+
+			defaultServer.afterStart = nil
+			defaultServer.afterQuit = nil
 		})
 	})
 }